@@ -1,6 +1,9 @@
 package commands
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/fatih/color"
 
 	"github.com/tlhunter/mig/config"
@@ -15,13 +18,20 @@ type CommandUpFamilyResult struct {
 }
 
 func CommandAll(cfg config.MigConfig) result.Response {
-	dbox, err := database.Connect(cfg.Connection)
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
 	if err != nil {
 		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
 	}
 
 	defer dbox.Db.Close()
 
+	// lets a container entrypoint initialize and migrate a fresh database in one step
+	if cfg.Init {
+		if _, _, failed := initTables(dbox); failed != nil {
+			return *failed
+		}
+	}
+
 	// First call to GetStatus is mostly unused. if it fails then don't continue.
 	status, err := migrations.GetStatus(cfg, dbox)
 	if err != nil {
@@ -34,6 +44,14 @@ func CommandAll(cfg config.MigConfig) result.Response {
 		return *result.NewError("There are no migrations to run.", "no_migrations")
 	}
 
+	if cfg.SingleTransaction {
+		return allInSingleTransaction(cfg, dbox, status)
+	}
+
+	// watching begins before locking so that an interrupt can't leave the lock behind
+	interrupts := watchInterrupts()
+	defer interrupts.Stop()
+
 	locked, err := database.ObtainLock(dbox)
 	if err != nil {
 		return *result.NewErrorWithDetails("Error obtaining lock for migration!", "obtain_lock", err)
@@ -42,6 +60,10 @@ func CommandAll(cfg config.MigConfig) result.Response {
 		return *result.NewError("Unable to obtain lock for migration!", "obtain_lock")
 	}
 
+	if failed := upgradeTables(dbox); failed != nil {
+		return *failed
+	}
+
 	highest, err := migrations.GetHighestValues(dbox)
 	batchId := highest.Batch
 
@@ -53,6 +75,10 @@ func CommandAll(cfg config.MigConfig) result.Response {
 	})
 
 	for {
+		if interrupts.Interrupted() {
+			break
+		}
+
 		status, err := migrations.GetStatus(cfg, dbox)
 		if err != nil {
 			return *result.NewErrorWithDetails("Encountered an error trying to get migrations status!", "retrieve_status", err)
@@ -70,25 +96,47 @@ func CommandAll(cfg config.MigConfig) result.Response {
 			return *result.NewErrorWithDetails("Error attempting to read next migration file!", "read_next_migration", err)
 		}
 
-		err = dbox.ExecMaybeTx(queries.Up, queries.UpTx)
-		if err != nil {
-			return *result.NewErrorWithDetails("Encountered an error while running migration!", "migration_failed", err)
+		if failed := checkDirectives(cfg, dbox, next, queries, status, false); failed != nil {
+			// nothing was executed so the lock can be released
+			res.SetErrorFrom(failed)
+			break
 		}
 
-		res.AddSuccessLn(color.GreenString("Migration %s was successfully applied!", next))
+		var migration migrations.MigrationRow
 
-		migration, err := migrations.AddMigrationWithBatch(dbox, next, batchId)
-		if err != nil {
-			res.SetError("The migration query executed but unable to track it in the migrations table!", "untracked_migration")
-			res.SetErrorDetails(err)
-			res.AddErrorLn("You may want to manually add it and investigate the error.")
-			res.AddErrorLn("Any remaining migrations will not be executed!")
-			return *res
+		ran, failed := execMigration(interrupts.Ctx, cfg, dbox, next, queries, false, "Encountered an error while running migration!", func(box database.DbBox, elapsed time.Duration) (err error) {
+			migration, err = migrations.AddMigrationWithBatch(box, next, batchId, migrations.NewExecution(elapsed, Version, queries.Checksum))
+			return err
+		})
+		if failed != nil {
+			if failed.ErrorCode == "untracked_migration" {
+				res.SetErrorFrom(failed)
+				res.AddErrorLn("Any remaining migrations will not be executed!")
+				return *res
+			}
+
+			if failed.ErrorCode != "migration_interrupted" {
+				return *failed
+			}
+
+			// the migration was rolled back so the tracking tables are consistent and the lock can be released
+			res.SetErrorFrom(failed)
+			break
+		}
+
+		if ran {
+			res.AddSuccessLn(color.GreenString("Migration %s was successfully applied!", next))
+		} else {
+			res.AddSuccessLn(color.YellowString("Migration %s doesn't run in the %s environment and was only recorded.", next, cfg.Environment))
 		}
 
 		executedMigrations = append(executedMigrations, migration)
 	}
 
+	if interrupts.Interrupted() {
+		addInterruptedSummary(res, len(executedMigrations))
+	}
+
 	released, err := database.ReleaseLock(dbox)
 	if err != nil {
 		res.SetError("Error releasing lock after running migration!", "release_lock")
@@ -100,3 +148,146 @@ func CommandAll(cfg config.MigConfig) result.Response {
 
 	return *res
 }
+
+type pendingMigration struct {
+	Name    string
+	Queries migrations.MigrationPair
+}
+
+// Runs every unapplied migration, and records it, in one transaction so that a failure leaves none of the batch applied.
+// Only databases with transactional DDL are supported and every migration must use a transaction.
+func allInSingleTransaction(cfg config.MigConfig, dbox database.DbBox, status migrations.MigrationStatus) result.Response {
+	if dbox.IsMysql {
+		return *result.NewError("MySQL implicitly commits DDL statements so migrations can't share a single transaction!", "single_transaction_unsupported")
+	}
+
+	// every migration of the batch is recorded in the batch transaction which therefore can't be read only
+	if cfg.TxSettings.ReadOnly || cfg.TxSettings.Deferrable {
+		return *result.NewError("A single transaction records the migrations so it can't be read only or deferrable!", "single_transaction_refused")
+	}
+
+	if err := dbox.ValidateTx(cfg.TxSettings); err != nil {
+		return *result.NewErrorWithDetails("Unsupported transaction settings for the batch!", "unsupported_transaction", err)
+	}
+
+	var pending []pendingMigration
+
+	// history is extended as if each migration was applied so requirements within the batch are satisfied
+	history := append([]migrations.MigrationRowStatus(nil), status.History...)
+
+	for i, entry := range status.History {
+		if entry.Status != "unapplied" {
+			continue
+		}
+
+		name := entry.Migration.Name
+
+		queries, err := migrations.GetQueriesFromFile(cfg.Migrations + "/" + name)
+		if err != nil {
+			return *result.NewErrorWithDetails(fmt.Sprintf("Error attempting to read migration file %s!", name), "read_next_migration", err)
+		}
+
+		if !queries.UpTx {
+			return *result.NewError(fmt.Sprintf("Migration %s uses NO TRANSACTION and can't run in a single transaction!", name), "single_transaction_refused")
+		}
+
+		if queries.Directives.HasTxSettings() {
+			return *result.NewError(fmt.Sprintf("Migration %s has transaction directives which can't apply to a single transaction!", name), "single_transaction_refused")
+		}
+
+		if failed := checkDirectives(cfg, dbox, name, queries, migrations.MigrationStatus{History: history}, false); failed != nil {
+			return *failed
+		}
+
+		history[i].Status = "applied"
+
+		pending = append(pending, pendingMigration{Name: name, Queries: queries})
+	}
+
+	interrupts := watchInterrupts()
+	defer interrupts.Stop()
+
+	locked, err := database.ObtainLock(dbox)
+	if err != nil {
+		return *result.NewErrorWithDetails("Error obtaining lock for migration!", "obtain_lock", err)
+	}
+	if !locked {
+		return *result.NewError("Unable to obtain lock for migration!", "obtain_lock")
+	}
+
+	if failed := upgradeTables(dbox); failed != nil {
+		return *failed
+	}
+
+	// the lock is held from here on so every path has to release it
+	res := runSingleTransaction(interrupts, cfg, dbox, pending)
+
+	released, err := database.ReleaseLock(dbox)
+	if err != nil {
+		res.SetError("Error releasing lock after running migration!", "release_lock")
+		return *res
+	}
+	if !released {
+		res.SetError("Unable to release lock after running migration!", "release_lock")
+	}
+
+	return *res
+}
+
+func runSingleTransaction(interrupts *interruptWatcher, cfg config.MigConfig, dbox database.DbBox, pending []pendingMigration) *result.Response {
+	highest, err := migrations.GetHighestValues(dbox)
+	if err != nil {
+		return result.NewErrorWithDetails("Unable to determine the next batch!", "retrieve_status", err)
+	}
+
+	batchId := highest.Batch
+
+	txbox, err := dbox.WithContext(interrupts.Ctx).Begin(cfg.TxSettings)
+	if err != nil {
+		return result.NewErrorWithDetails("Unable to begin the batch transaction!", "migration_failed", err)
+	}
+
+	defer txbox.Rollback()
+
+	var executedMigrations []migrations.MigrationRow
+
+	res := result.NewSerializable(color.HiWhiteString("Running migrations for batch %d in a single transaction...", batchId), CommandUpFamilyResult{
+		MigrationBatch: batchId,
+		Migrations:     &executedMigrations,
+	})
+
+	for _, next := range pending {
+		if interrupts.Interrupted() {
+			res.SetError("Interrupted before the batch completed!", "migration_interrupted")
+			res.AddErrorLn("The transaction was rolled back and none of the migrations were applied.")
+			return res
+		}
+
+		var migration migrations.MigrationRow
+
+		ran, failed := execMigration(interrupts.Ctx, cfg, txbox, next.Name, next.Queries, false, fmt.Sprintf("Encountered an error while running migration %s!", next.Name), func(box database.DbBox, elapsed time.Duration) (err error) {
+			migration, err = migrations.AddMigrationWithBatch(box, next.Name, batchId, migrations.NewExecution(elapsed, Version, next.Queries.Checksum))
+			return err
+		})
+		if failed != nil {
+			failed.AddErrorLn("None of the migrations in the batch were applied.")
+			return failed
+		}
+
+		if ran {
+			res.AddSuccessLn(color.GreenString("Migration %s was successfully applied!", next.Name))
+		} else {
+			res.AddSuccessLn(color.YellowString("Migration %s doesn't run in the %s environment and was only recorded.", next.Name, cfg.Environment))
+		}
+
+		executedMigrations = append(executedMigrations, migration)
+	}
+
+	if err = txbox.Commit(); err != nil {
+		res := result.NewErrorWithDetails("Unable to commit the batch transaction!", "migration_failed", err)
+		res.AddErrorLn("None of the migrations in the batch were applied.")
+		return res
+	}
+
+	return res
+}
@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tlhunter/mig/config"
+	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/migrations"
+)
+
+func TestAllInSingleTransactionReadOnly(t *testing.T) {
+	dbox, err := database.Connect("sqlite::memory:", database.ConnectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer dbox.Db.Close()
+
+	cfg := config.MigConfig{TxSettings: database.TxSettings{ReadOnly: true}}
+
+	res := allInSingleTransaction(cfg, dbox, migrations.MigrationStatus{})
+	assert.Equal(t, "single_transaction_refused", res.ErrorCode, "the batch transaction records the migrations")
+}
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/tlhunter/mig/config"
+	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/migrations"
+	"github.com/tlhunter/mig/result"
+)
+
+type CommandDiffResult struct {
+	Error       string                      `json:"error,omitempty"`
+	ErrorCode   string                      `json:"code,omitempty"`
+	Differences []database.SchemaDifference `json:"differences"`
+}
+
+// Compares the schema of the database with the schema built by applying every migration to a scratch
+// database, reporting tables, columns, and indexes which were changed outside of mig, e.g. by a hotfix
+func CommandDiff(cfg config.MigConfig) result.Response {
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
+	if err != nil {
+		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
+	}
+
+	defer dbox.Db.Close()
+
+	scratch, cleanup, failed := openScratch(cfg)
+	if failed != nil {
+		return *failed
+	}
+
+	defer cleanup()
+
+	scratchCfg := cfg
+	scratchCfg.Connection = scratch.Connection
+	scratchCfg.Init = true
+	scratchCfg.SingleTransaction = false
+
+	if applied := CommandAll(scratchCfg); applied.ErrorCode != "" && applied.ErrorCode != "no_migrations" {
+		res := result.NewError("Unable to apply the migrations to the scratch database!", "diff_scratch")
+		res.Details = responseDetails(applied)
+		addScratchHint(res, cfg, "diff")
+		return *res
+	}
+
+	expected, err := scratch.DescribeSchema(migrations.TrackingTables())
+	if err != nil {
+		return *result.NewErrorWithDetails("Unable to introspect the scratch database!", "diff_scratch", err)
+	}
+
+	actual, err := dbox.DescribeSchema(migrations.TrackingTables())
+	if err != nil {
+		return *result.NewErrorWithDetails("Unable to introspect the database!", "describe_schema", err)
+	}
+
+	// every database reports column types differently, so only names are compared across them
+	differences := database.CompareSchemas(expected, actual, scratch.Type == dbox.Type)
+
+	if len(differences) == 0 {
+		return *result.NewSerializable("The database matches the migrations.", CommandDiffResult{
+			Differences: []database.SchemaDifference{},
+		})
+	}
+
+	message := "The database doesn't match the migrations!"
+	res := result.NewError(message, "schema_drift")
+	res.ExitStatus = 9
+
+	for _, difference := range differences {
+		res.AddErrorLn(color.WhiteString("* %s", difference.Message))
+	}
+
+	if status, err := migrations.GetStatus(cfg, dbox); err == nil && status.Unapplied > 0 {
+		res.AddErrorLn(color.WhiteString("The scratch database includes the unapplied migrations, see `mig status`."))
+	}
+
+	res.Serializable = CommandDiffResult{
+		Error:       message,
+		ErrorCode:   "schema_drift",
+		Differences: differences,
+	}
+
+	return *res
+}
+
+type scratchDatabase struct {
+	database.DbBox
+	Connection string
+}
+
+// Connects to the scratch database, a temporary sqlite database unless --scratch is provided. The returned
+// function closes the connection and removes the temporary database or empties the named scratch database.
+func openScratch(cfg config.MigConfig) (scratchDatabase, func(), *result.Response) {
+	scratch := scratchDatabase{Connection: cfg.Scratch}
+	cleanup := func() {}
+
+	if cfg.Scratch != "" && cfg.Scratch == cfg.Connection {
+		return scratch, cleanup, result.NewError("Refusing to use the database as its own scratch database!", "scratch_is_target")
+	}
+
+	tempDir := ""
+
+	if cfg.Scratch == "" {
+		dir, err := os.MkdirTemp("", "mig-scratch-")
+		if err != nil {
+			return scratch, cleanup, result.NewErrorWithDetails("Unable to create a temporary scratch database!", "scratch_conn", err)
+		}
+
+		tempDir = dir
+		scratch.Connection = "sqlite://" + filepath.ToSlash(filepath.Join(dir, "scratch.db"))
+	}
+
+	dbox, err := database.Connect(scratch.Connection, cfg.ConnectOptions())
+	if err != nil {
+		if tempDir != "" {
+			os.RemoveAll(tempDir)
+		}
+		return scratch, cleanup, result.NewErrorWithDetails("scratch database connection error", "scratch_conn", err)
+	}
+
+	scratch.DbBox = dbox
+
+	if tempDir != "" {
+		return scratch, func() {
+			dbox.Db.Close()
+			os.RemoveAll(tempDir)
+		}, nil
+	}
+
+	tables, err := dbox.ListTables()
+	if err != nil {
+		dbox.Db.Close()
+		return scratch, cleanup, result.NewErrorWithDetails("Unable to list the tables of the scratch database!", "scratch_conn", err)
+	}
+
+	if len(tables) > 0 {
+		dbox.Db.Close()
+		res := result.NewError("Refusing to use a scratch database which already has tables!", "scratch_not_empty")
+		res.AddErrorLn(fmt.Sprintf("Found the tables: %s", strings.Join(tables, ", ")))
+		return scratch, cleanup, res
+	}
+
+	return scratch, func() {
+		dbox.ClearSchema()
+		dbox.Db.Close()
+	}, nil
+}
+
+// Migrations written for another database often fail on the temporary sqlite database
+func addScratchHint(res *result.Response, cfg config.MigConfig, command string) {
+	if cfg.Scratch != "" {
+		return
+	}
+
+	res.AddErrorLn(color.WhiteString("Migrations which don't run on sqlite need a scratch database of the same kind:"))
+	res.AddErrorLn(color.WhiteString("$ mig %s --scratch=\"postgresql://localhost/scratch\"", command))
+}
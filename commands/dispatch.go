@@ -10,6 +10,10 @@ import (
 func Dispatch(cfg config.MigConfig, subcommands []string) result.Response {
 	var res result.Response
 
+	if denied := ConfirmProtected(cfg, subcommands[0]); denied != nil {
+		return *denied
+	}
+
 	switch subcommands[0] {
 	case "create":
 		if len(subcommands) >= 2 {
@@ -51,6 +55,34 @@ func Dispatch(cfg config.MigConfig, subcommands []string) result.Response {
 			res.SetError("usage: mig upto \"<migration name>\"", "command_usage")
 		}
 
+	case "squash":
+		if len(subcommands) >= 2 {
+			res = CommandSquash(cfg, subcommands[1])
+		} else {
+			res.SetError("usage: mig squash \"<migration name>\"", "command_usage")
+		}
+
+	case "rename-applied":
+		res = CommandRenameApplied(cfg)
+
+	case "dump-schema":
+		res = CommandDumpSchema(cfg)
+
+	case "load-schema":
+		res = CommandLoadSchema(cfg)
+
+	case "diff":
+		res = CommandDiff(cfg)
+
+	case "verify-roundtrip":
+		res = CommandVerifyRoundtrip(cfg)
+
+	case "doctor":
+		res = CommandDoctor(cfg)
+
+	case "upgrade-tables":
+		res = CommandUpgradeTables(cfg)
+
 	case "version":
 		res = CommandVersion(cfg)
 
@@ -58,6 +90,10 @@ func Dispatch(cfg config.MigConfig, subcommands []string) result.Response {
 		res.SetError(fmt.Sprintf("unsupported command %s", subcommands[0]), "command_unknown")
 	}
 
+	if cfg.DumpSchema && DUMP_SCHEMA_COMMANDS[subcommands[0]] && res.ErrorCode == "" {
+		dumpSchemaAfter(cfg, &res)
+	}
+
 	return res
 
 }
@@ -0,0 +1,443 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/tlhunter/mig/config"
+	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/migrations"
+	"github.com/tlhunter/mig/result"
+)
+
+type DoctorStep struct {
+	Code        string `json:"code"`        // machine-keyable problem code
+	Description string `json:"description"` // the fix, or the problem when it has to be fixed manually
+	Fixable     bool   `json:"fixable"`
+	Applied     bool   `json:"applied"`
+
+	fix     func() error
+	confirm string // the word which must be typed to apply the fix, even with --fix
+}
+
+type CommandDoctorResult struct {
+	Error     string       `json:"error,omitempty"`
+	ErrorCode string       `json:"code,omitempty"`
+	Healthy   bool         `json:"healthy"`
+	Steps     []DoctorStep `json:"steps"`
+}
+
+// Diagnoses the tracking tables and repairs them, either prompting for every fix or applying them all with --fix.
+// The problems are diagnosed again after every fix since fixing one can reveal another.
+func CommandDoctor(cfg config.MigConfig) result.Response {
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
+	if err != nil {
+		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
+	}
+
+	defer dbox.Db.Close()
+
+	interactive := !cfg.Fix && isInteractive(cfg)
+	reader := bufio.NewReader(os.Stdin)
+
+	var steps []DoctorStep       // every fix which was applied or declined
+	var remaining []DoctorStep   // problems which are still present
+	handled := map[string]bool{} // description of a handled fix, true when it was applied
+
+	for {
+		found, failed := diagnose(cfg, dbox)
+		if failed != nil {
+			return *failed
+		}
+
+		if !cfg.Fix && !interactive {
+			remaining = found
+			break
+		}
+
+		var next *DoctorStep
+		remaining = nil
+
+		for i, step := range found {
+			if applied, ok := handled[step.Description]; ok && applied {
+				return *result.NewError(fmt.Sprintf("The problem remained after applying the fix to %s!", step.Description), "doctor_failed")
+			} else if ok || step.fix == nil || next != nil {
+				remaining = append(remaining, step)
+			} else {
+				next = &found[i]
+			}
+		}
+
+		if next == nil {
+			break
+		}
+
+		if next.confirm != "" {
+			// a fix which is only safe when the user knows more than mig does is never applied without asking
+			if !isInteractive(cfg) {
+				handled[next.Description] = false
+				steps = append(steps, *next)
+				continue
+			}
+
+			fmt.Fprintln(os.Stderr, color.RedString("* %s", next.Description))
+			fmt.Fprint(os.Stderr, color.WhiteString("Type %s to apply this fix: ", next.confirm))
+
+			answer, err := reader.ReadString('\n')
+			if err != nil {
+				return *result.NewErrorWithDetails("unable to read confirmation", "doctor_failed", err)
+			}
+
+			if strings.TrimSpace(answer) != next.confirm {
+				handled[next.Description] = false
+				steps = append(steps, *next)
+				continue
+			}
+		} else if interactive {
+			fmt.Fprintln(os.Stderr, color.YellowString("* %s", next.Description))
+			fmt.Fprint(os.Stderr, color.WhiteString("Apply this fix? [y/N] "))
+
+			answer, err := reader.ReadString('\n')
+			if err != nil {
+				return *result.NewErrorWithDetails("unable to read confirmation", "doctor_failed", err)
+			}
+
+			if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+				handled[next.Description] = false
+				steps = append(steps, *next)
+				continue
+			}
+		} else if !cfg.OutputJson {
+			fmt.Println(color.WhiteString("* %s", next.Description))
+		}
+
+		if err = next.fix(); err != nil {
+			return *result.NewErrorWithDetails(fmt.Sprintf("Unable to %s!", next.Description), "doctor_failed", err)
+		}
+
+		next.Applied = true
+		handled[next.Description] = true
+		steps = append(steps, *next)
+	}
+
+	applied := 0
+	for _, step := range steps {
+		if step.Applied {
+			applied++
+		}
+	}
+
+	if len(remaining) == 0 {
+		message := "mig is healthy, there is nothing to fix."
+		if applied > 0 {
+			message = fmt.Sprintf("Applied %d fixes, mig is healthy.", applied)
+		}
+
+		if steps == nil {
+			steps = []DoctorStep{}
+		}
+
+		return *result.NewSerializable(message, CommandDoctorResult{
+			Healthy: true,
+			Steps:   steps,
+		})
+	}
+
+	message := "mig found problems with the tracking tables!"
+	res := result.NewError(message, "doctor_problems")
+	res.ExitStatus = 9
+
+	fixable := false
+	confirmable := false
+	for _, step := range remaining {
+		if step.confirm != "" {
+			confirmable = true
+		}
+
+		if step.Fixable {
+			fixable = true
+			res.AddErrorLn(color.WhiteString("* %s", step.Description))
+		} else {
+			res.AddErrorLn(color.WhiteString("* %s (must be fixed manually)", step.Description))
+		}
+	}
+
+	if fixable && !cfg.Fix && !interactive {
+		res.AddErrorLn(color.WhiteString("Run the command from an interactive terminal to choose the fixes, or apply all of them:"))
+		res.AddErrorLn(color.WhiteString("$ mig doctor --fix"))
+	} else if confirmable && !isInteractive(cfg) {
+		res.AddErrorLn(color.WhiteString("Clearing the lock must be confirmed from an interactive terminal, or run `mig unlock` once no other mig process is running."))
+	}
+
+	res.Serializable = CommandDoctorResult{
+		Error:     message,
+		ErrorCode: "doctor_problems",
+		Healthy:   false,
+		Steps:     append(steps, remaining...),
+	}
+
+	return *res
+}
+
+// Returns the problems with the tracking tables, most fundamental first. Later checks are skipped
+// while there are fixable problems they depend on, e.g. the ids aren't checked without a migrations table.
+func diagnose(cfg config.MigConfig, dbox database.DbBox) ([]DoctorStep, *result.Response) {
+	existMigrations, err := dbox.TableExists("migrations")
+	if err != nil {
+		return nil, result.NewErrorWithDetails("unable to tell if 'migrations' table exists!", "unable_check_migrations", err)
+	}
+
+	existLock, err := dbox.TableExists("migrations_lock")
+	if err != nil {
+		return nil, result.NewErrorWithDetails("unable to tell if 'migrations_lock' table exists!", "unable_check_migrations_lock", err)
+	}
+
+	existBackup, err := migrations.BackupExists(dbox)
+	if err != nil {
+		return nil, result.NewErrorWithDetails("unable to tell if 'migrations_backup' table exists!", "unable_check_migrations", err)
+	}
+
+	if !existMigrations && !existLock && !existBackup {
+		res := result.NewError("The tables used for tracking migrations are missing.", "missing_tables")
+		res.AddErrorLn(color.WhiteString("This likely means that mig hasn't yet been initialized:"))
+		res.AddErrorLn(color.WhiteString("$ mig init"))
+		return nil, res
+	}
+
+	repair := func() error {
+		_, err := migrations.RepairTables(dbox)
+		return err
+	}
+
+	if !existMigrations && existBackup {
+		return []DoctorStep{newStep("restore_backup", "rename the migrations_backup table to migrations", func() error {
+			return migrations.RestoreBackup(dbox)
+		})}, nil
+	}
+
+	if !existMigrations {
+		return []DoctorStep{newStep("missing_table", "recreate the missing migrations table, which will be empty", repair)}, nil
+	}
+
+	if !existLock {
+		return []DoctorStep{newStep("missing_table", "recreate the missing migrations_lock table", repair)}, nil
+	}
+
+	version, err := migrations.GetTablesVersion(dbox)
+	if err != nil {
+		return nil, result.NewErrorWithDetails("Unable to determine the version of the mig tables!", "upgrade_tables", err)
+	}
+
+	if version > migrations.SCHEMA_VERSION {
+		return nil, result.NewError("The mig tables were created by a newer version of mig! Upgrade mig instead.", "tables_too_new")
+	}
+
+	problems, err := migrations.CheckTables(dbox, version)
+	if err != nil {
+		return nil, result.NewErrorWithDetails("Unable to check the mig tables!", "invalid_tables", err)
+	}
+
+	var steps []DoctorStep
+	fixable := false
+	resetLock := false
+
+	for _, problem := range problems {
+		switch {
+		case problem.Code == "missing_lock_row":
+			steps = append(steps, newStep(problem.Code, "add the missing migrations_lock row", repair))
+			fixable = true
+		case problem.Code == "multiple_lock_rows" || problem.Code == "invalid_lock_row" || problem.Code == "invalid_lock_value":
+			if !resetLock {
+				steps = append(steps, newStep("invalid_lock_rows", "replace the migrations_lock rows with a single unlocked row", func() error {
+					return migrations.ResetLockRows(dbox)
+				}))
+			}
+			resetLock = true
+			fixable = true
+		case problem.Code == "missing_table" && problem.Table == "migrations_history":
+			steps = append(steps, newStep(problem.Code, "recreate the missing migrations_history table", func() error {
+				_, err := dbox.Exec(migrations.CREATE_HISTORY)
+				return err
+			}))
+			fixable = true
+		default:
+			steps = append(steps, DoctorStep{Code: problem.Code, Description: problem.Message})
+		}
+	}
+
+	if fixable {
+		return steps, nil
+	}
+
+	var isLocked int
+	if err = dbox.QueryRow(LOCK_STATUS).Scan(&isLocked); err != nil {
+		return nil, result.NewErrorWithDetails("unable to determine lock status!", "unable_check_lock", err)
+	}
+
+	// every remaining fix requires the lock, which can't be told apart from the lock of a running mig process
+	if isLocked == 1 {
+		step := newStep("stale_lock", "clear the lock, which is only stale when no other mig process is running", func() error {
+			released, err := database.ReleaseLock(dbox)
+			if err == nil && !released {
+				err = errors.New("the lock was already released")
+			}
+			return err
+		})
+		step.confirm = "unlock"
+
+		return append(steps, step), nil
+	}
+
+	if version < migrations.SCHEMA_VERSION {
+		return append(steps, newStep("outdated_tables", fmt.Sprintf("upgrade the mig tables from version %d to %d", version, migrations.SCHEMA_VERSION), func() error {
+			return withLock(dbox, func() error {
+				_, err := migrations.UpgradeTables(dbox)
+				return err
+			})
+		})), nil
+	}
+
+	migRows, err := migrations.ListRows(dbox)
+	if err != nil {
+		return nil, result.NewErrorWithDetails("unable to list migrations!", "list_rows", err)
+	}
+
+	for i, row := range migRows {
+		if row.Id != i+1 {
+			steps = append(steps, newStep("id_gaps", fmt.Sprintf("renumber the migrations ids from 1 to %d", len(migRows)), func() error {
+				return withLock(dbox, func() error {
+					return migrations.RenumberIds(dbox)
+				})
+			}))
+			break
+		}
+	}
+
+	renames, failed := diagnoseRenames(cfg, dbox, migRows)
+	if failed != nil {
+		return nil, failed
+	}
+
+	steps = append(steps, renames...)
+
+	var unrecorded []migrations.MigrationRow
+	for _, row := range migRows {
+		if row.Checksum == "" {
+			if _, err := os.Stat(cfg.Migrations + "/" + row.Name); err == nil {
+				unrecorded = append(unrecorded, row)
+			}
+		}
+	}
+
+	if len(unrecorded) > 0 {
+		steps = append(steps, newStep("missing_checksums", "record the missing checksums of migrations applied by an older version of mig", func() error {
+			return withLock(dbox, func() error {
+				for _, row := range unrecorded {
+					checksum, err := migrations.FileChecksum(cfg.Migrations + "/" + row.Name)
+					if err != nil {
+						return err
+					}
+
+					if err = migrations.SetChecksum(dbox, row.Id, checksum); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+		}))
+	}
+
+	return steps, nil
+}
+
+// An applied migration whose file is missing was renamed when exactly one unapplied file has the same checksum
+func diagnoseRenames(cfg config.MigConfig, dbox database.DbBox, migRows []migrations.MigrationRow) ([]DoctorStep, *result.Response) {
+	var steps []DoctorStep
+
+	migFiles, err := migrations.ListFiles(cfg.Migrations, cfg.SchemaFile)
+	if err != nil {
+		return nil, result.NewErrorWithDetails("unable to list migration files!", "list_files", err)
+	}
+
+	onDisk := map[string]bool{}
+	for _, migFile := range migFiles {
+		onDisk[migFile] = true
+	}
+
+	applied := map[string]bool{}
+	missing := map[string]int{} // missing migrations by checksum
+	for _, row := range migRows {
+		applied[row.Name] = true
+		if !onDisk[row.Name] && row.Checksum != "" {
+			missing[row.Checksum]++
+		}
+	}
+
+	if len(missing) == 0 {
+		return steps, nil
+	}
+
+	unapplied := map[string][]string{} // unapplied files by checksum
+	for _, migFile := range migFiles {
+		if applied[migFile] {
+			continue
+		}
+
+		checksum, err := migrations.FileChecksum(cfg.Migrations + "/" + migFile)
+		if err != nil {
+			return nil, result.NewErrorWithDetails("unable to read migration file!", "read_file", err)
+		}
+
+		unapplied[checksum] = append(unapplied[checksum], migFile)
+	}
+
+	for _, row := range migRows {
+		if onDisk[row.Name] || row.Checksum == "" || missing[row.Checksum] != 1 || len(unapplied[row.Checksum]) != 1 {
+			continue
+		}
+
+		row := row
+		renamed := unapplied[row.Checksum][0]
+
+		steps = append(steps, newStep("renamed_migration", fmt.Sprintf("rename the applied migration %s to %s, which has the same content", row.Name, renamed), func() error {
+			return withLock(dbox, func() error {
+				return migrations.RenameMigration(dbox, row.Id, row.Name, renamed)
+			})
+		}))
+	}
+
+	return steps, nil
+}
+
+func newStep(code string, description string, fix func() error) DoctorStep {
+	return DoctorStep{
+		Code:        code,
+		Description: description,
+		Fixable:     true,
+		fix:         fix,
+	}
+}
+
+// Applies a fix while holding the lock so that migrations can't run at the same time
+func withLock(dbox database.DbBox, fix func() error) error {
+	locked, err := database.ObtainLock(dbox)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return errors.New("unable to obtain the lock")
+	}
+
+	err = fix()
+
+	if _, releaseErr := database.ReleaseLock(dbox); err == nil {
+		err = releaseErr
+	}
+
+	return err
+}
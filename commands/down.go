@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/tlhunter/mig/config"
 	"github.com/tlhunter/mig/database"
@@ -10,7 +11,7 @@ import (
 )
 
 func CommandDown(cfg config.MigConfig) result.Response {
-	dbox, err := database.Connect(cfg.Connection)
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
 	if err != nil {
 		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
 	}
@@ -39,6 +40,14 @@ func CommandDown(cfg config.MigConfig) result.Response {
 		return res
 	}
 
+	if failed := checkDirectives(cfg, dbox, last.Name, queries, status, true); failed != nil {
+		return *failed
+	}
+
+	// watching begins before locking so that an interrupt can't leave the lock behind
+	interrupts := watchInterrupts()
+	defer interrupts.Stop()
+
 	locked, err := database.ObtainLock(dbox)
 	if err != nil {
 		return *result.NewErrorWithDetails("Error obtaining lock for migration down!", "obtain_lock", err)
@@ -47,19 +56,39 @@ func CommandDown(cfg config.MigConfig) result.Response {
 		return *result.NewError("Unable to obtain lock for migrating down!", "obtain_lock")
 	}
 
-	err = dbox.ExecMaybeTx(queries.Down, queries.DownTx)
-	if err != nil {
-		return *result.NewErrorWithDetails("Encountered an error while running down migration!", "migration_failed", err)
+	if failed := upgradeTables(dbox); failed != nil {
+		return *failed
 	}
 
-	res := result.NewSuccess(fmt.Sprintf("Down migration for %s was successfully applied!", last.Name))
+	ran, failed := execMigration(interrupts.Ctx, cfg, dbox, last.Name, queries, true, "Encountered an error while running down migration!", func(box database.DbBox, elapsed time.Duration) error {
+		execution := migrations.NewExecution(elapsed, Version, queries.Checksum)
+
+		// a squashed migration applied as the original migrations removes all of their rows
+		if len(last.Replaced) > 0 {
+			for i := len(last.Replaced) - 1; i >= 0; i-- {
+				replaced := last.Replaced[i]
+				if err := migrations.RemoveMigration(box, replaced.StoredName(), replaced.Id, replaced.Batch, execution); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		return migrations.RemoveMigration(box, last.StoredName(), last.Id, last.Batch, execution)
+	})
+	if failed != nil {
+		if failed.ErrorCode == "migration_interrupted" {
+			// the migration was rolled back so the tracking tables are consistent and the lock can be released
+			database.ReleaseLock(dbox)
+		}
+
+		return *failed
+	}
 
-	err = migrations.RemoveMigration(dbox, last.Name, last.Id)
-	if err != nil {
-		res.SetError("The migration down query executed but unable to track it in the migrations table!", "untracked_migration")
-		res.SetErrorDetails(err)
-		res.AddErrorLn("You may want to manually remove it and investigate the error.")
-		return *res
+	res := result.NewSuccess(fmt.Sprintf("Down migration for %s was successfully applied!", last.Name))
+	if !ran {
+		res = result.NewSuccess(fmt.Sprintf("Migration %s doesn't run in the %s environment and was only removed from the migrations table.", last.Name, cfg.Environment))
 	}
 
 	released, err := database.ReleaseLock(dbox)
@@ -0,0 +1,249 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/tlhunter/mig/config"
+	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/migrations"
+	"github.com/tlhunter/mig/result"
+)
+
+// Runs the up or down block of the named migration, honoring the directives of the migration, then calls record with
+// the time the block took to add or remove the row in the migrations table. When the block uses a transaction record runs as part of it
+// so that the migration and its row are committed together, unless the transaction is read only.
+// The running statement is cancelled, and its transaction rolled back, when the block exceeds
+// the migration timeout or when ctx is cancelled, e.g. by an interrupt.
+// Queries outside of a transaction can't be rolled back so they ignore ctx and only honor the timeout.
+// Returns whether the block was executed, which it isn't when the migration is restricted to other
+// environments, along with a response using failure as the error message when it fails.
+func execMigration(ctx context.Context, cfg config.MigConfig, dbox database.DbBox, name string, pair migrations.MigrationPair, down bool, failure string, record func(database.DbBox, time.Duration) error) (bool, *result.Response) {
+	direction := "up"
+	if down {
+		direction = "down"
+	}
+
+	logger := slog.With("migration", name, "direction", direction)
+
+	runs, err := pair.Directives.RunsIn(cfg.Environment)
+	if err != nil {
+		return false, result.NewErrorWithDetails("Unable to determine if the migration runs in this environment!", "migration_env", err)
+	}
+	if !runs {
+		logger.Info("skipping migration in this environment", "environment", cfg.Environment)
+
+		if err = record(dbox, 0); err != nil {
+			return false, untrackedMigration(err, down)
+		}
+
+		return false, nil
+	}
+
+	query, transaction := pair.Up, pair.UpTx
+	if down {
+		query, transaction = pair.Down, pair.DownTx
+	}
+
+	timeout := cfg.Timeout
+	if pair.Directives.Timeout > 0 {
+		timeout = pair.Directives.Timeout
+	}
+
+	if !transaction {
+		ctx = context.Background()
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	box := dbox.WithContext(ctx)
+
+	logger.Info("running migration", "transaction", transaction, "timeout", timeout)
+	start := time.Now()
+
+	if transaction {
+		err = execAndRecord(box, query, pair.Directives.TxSettings(cfg.TxSettings), record)
+
+		var untracked trackingError
+		if errors.As(err, &untracked) {
+			logger.Error("unable to track migration", "error", untracked.err)
+			res := result.NewErrorWithDetails("Unable to track the migration in the migrations table!", "migration_failed", untracked.err)
+			res.AddErrorLn("The transaction was rolled back so the migration wasn't applied.")
+			return true, res
+		}
+	} else {
+		err = box.ExecMaybeTx(query, false, database.TxSettings{})
+		if err == nil {
+			// the queries can't be undone so the row is recorded regardless of the timeout
+			if err = record(dbox, time.Since(start)); err != nil {
+				logger.Error("unable to track migration", "error", err)
+				return true, untrackedMigration(err, down)
+			}
+		}
+	}
+
+	if err == nil {
+		logger.Info("finished migration", "elapsed", time.Since(start))
+		return true, nil
+	}
+
+	logger.Error("migration failed", "elapsed", time.Since(start), "error", err)
+
+	var res *result.Response
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		res = result.NewErrorWithDetails(fmt.Sprintf("Migration exceeded the timeout of %s and was cancelled!", timeout), "migration_timeout", err)
+	} else if ctx.Err() != nil {
+		res = result.NewErrorWithDetails("Migration was interrupted and cancelled!", "migration_interrupted", err)
+	} else {
+		return true, result.NewErrorWithDetails(failure, "migration_failed", err)
+	}
+
+	if transaction {
+		res.AddErrorLn("The transaction was rolled back and the migration wasn't recorded.")
+	} else {
+		res.AddErrorLn("The migration doesn't use a transaction and may have been partially applied!")
+	}
+
+	return true, res
+}
+
+// An error updating the migrations table after the queries of the migration succeeded
+type trackingError struct {
+	err error
+}
+
+func (e trackingError) Error() string {
+	return e.err.Error()
+}
+
+// Runs the query followed by record in one transaction, joining the transaction of the box when there is one,
+// e.g. when the whole batch runs in a single transaction.
+// A read only transaction refuses the writes of record so it runs in a transaction of its own once the query,
+// which can't have changed anything, is committed.
+func execAndRecord(box database.DbBox, query string, settings database.TxSettings, record func(database.DbBox, time.Duration) error) error {
+	ownsTx := !box.InTx()
+
+	if ownsTx && settings.ReadOnly {
+		start := time.Now()
+		if err := box.ExecMaybeTx(query, true, settings); err != nil {
+			return err
+		}
+
+		elapsed := time.Since(start)
+
+		recordBox, err := box.Begin(database.TxSettings{})
+		if err != nil {
+			return trackingError{err}
+		}
+
+		defer recordBox.Rollback()
+
+		if err = record(recordBox, elapsed); err != nil {
+			return trackingError{err}
+		}
+
+		if err = recordBox.Commit(); err != nil {
+			return trackingError{err}
+		}
+
+		return nil
+	}
+
+	if ownsTx {
+		var err error
+		box, err = box.Begin(settings)
+		if err != nil {
+			return err
+		}
+
+		defer box.Rollback()
+	}
+
+	start := time.Now()
+	if err := box.ExecMaybeTx(query, true, settings); err != nil {
+		return err
+	}
+
+	if err := record(box, time.Since(start)); err != nil {
+		return trackingError{err}
+	}
+
+	if ownsTx {
+		return box.Commit()
+	}
+
+	return nil
+}
+
+// The queries of a migration without a transaction ran but the migrations table couldn't be updated
+func untrackedMigration(err error, down bool) *result.Response {
+	if down {
+		res := result.NewErrorWithDetails("The migration down query executed but unable to track it in the migrations table!", "untracked_migration", err)
+		res.AddErrorLn("You may want to manually remove it and investigate the error.")
+		return res
+	}
+
+	res := result.NewErrorWithDetails("The migration query executed but unable to track it in the migrations table!", "untracked_migration", err)
+	res.AddErrorLn("You may want to manually add it and investigate the error.")
+	return res
+}
+
+// Ensures that a migration can run: its environment can be determined, the database supports its
+// transaction settings and its required migrations have been applied.
+// Requirements are only checked when migrating up.
+func checkDirectives(cfg config.MigConfig, dbox database.DbBox, name string, pair migrations.MigrationPair, status migrations.MigrationStatus, down bool) *result.Response {
+	_, err := pair.Directives.RunsIn(cfg.Environment)
+	if err != nil {
+		return result.NewErrorWithDetails(fmt.Sprintf("Unable to determine if migration %s runs in this environment!", name), "migration_env", err)
+	}
+
+	transaction := pair.UpTx
+	if down {
+		transaction = pair.DownTx
+	}
+
+	if transaction {
+		err = dbox.ValidateTx(pair.Directives.TxSettings(cfg.TxSettings))
+		if err != nil {
+			return result.NewErrorWithDetails(fmt.Sprintf("Unsupported transaction settings for migration %s!", name), "unsupported_transaction", err)
+		}
+	}
+
+	if down {
+		return nil
+	}
+
+	missing := pair.Directives.MissingRequirements(status.History)
+	if len(missing) > 0 {
+		return result.NewError(fmt.Sprintf("Migration %s requires %s which hasn't been applied!", name, strings.Join(missing, ", ")), "missing_requirement")
+	}
+
+	if replaced := pair.Directives.AppliedReplacements(status.History); len(replaced) > 0 {
+		res := result.NewError(fmt.Sprintf("Migration %s squashes migrations which were only partly applied: %s!", name, strings.Join(replaced, ", ")), "partially_squashed")
+		res.AddErrorLn("Running it would apply those migrations a second time.")
+		res.AddErrorLn(fmt.Sprintf("Restore the remaining migrations from the %s directory and apply them first.", migrations.ARCHIVE_DIR))
+		return res
+	}
+
+	return nil
+}
+
+// Upgrades tracking tables created by an older version of mig, releasing the lock when that fails
+func upgradeTables(dbox database.DbBox) *result.Response {
+	_, err := migrations.UpgradeTables(dbox)
+	if err != nil {
+		database.ReleaseLock(dbox)
+		return result.NewErrorWithDetails("Unable to upgrade the mig tables!", "upgrade_tables", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tlhunter/mig/config"
+	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/migrations"
+)
+
+func TestExecMigrationReadOnly(t *testing.T) {
+	dbox, err := database.Connect("sqlite::memory:", database.ConnectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer dbox.Db.Close()
+
+	if err = migrations.CreateTables(dbox); err != nil {
+		t.Fatal(err)
+	}
+
+	readOnly := true
+	pair := migrations.MigrationPair{
+		Up:         "SELECT 1;",
+		UpTx:       true,
+		Directives: migrations.Directives{Isolation: sql.LevelSerializable, ReadOnly: &readOnly},
+	}
+
+	ran, failed := execMigration(context.Background(), config.MigConfig{}, dbox, "1_check.sql", pair, false, "failed", func(box database.DbBox, elapsed time.Duration) error {
+		assert.True(t, box.InTx(), "the migration is recorded in a transaction")
+		_, err := migrations.AddMigration(box, "1_check.sql", migrations.NewExecution(elapsed, "test", ""))
+		return err
+	})
+	assert.True(t, ran)
+	assert.Nil(t, failed)
+
+	rows, err := migrations.ListRows(dbox)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1, "a read only migration is recorded")
+
+	runs, err := migrations.ListRuns(dbox)
+	assert.NoError(t, err)
+	assert.Len(t, runs["1_check.sql"], 1)
+}
@@ -1,150 +1,112 @@
 package commands
 
 import (
+	"github.com/fatih/color"
 	"github.com/tlhunter/mig/config"
 	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/migrations"
 	"github.com/tlhunter/mig/result"
 )
 
 func CommandInit(cfg config.MigConfig) result.Response {
-	dbox, err := database.Connect(cfg.Connection)
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
 	if err != nil {
 		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
 	}
 
 	defer dbox.Db.Close()
 
-	if dbox.IsPostgres {
-		err = postgresInit(dbox)
-	} else if dbox.IsMysql {
-		err = mysqlInit(dbox)
-	} else if dbox.IsSqlite {
-		err = sqliteInit(dbox)
-	} else {
-		panic("unknown database: " + dbox.Type)
-	}
+	if cfg.Strict {
+		initialized, err := isInitialized(dbox)
+		if err != nil {
+			return *result.NewErrorWithDetails("error initializing mig!", "unable_init", err)
+		}
 
-	if err != nil {
-		return *result.NewErrorWithDetails("error initializing mig!", "unable_init", err)
+		if initialized {
+			return *result.NewError("mig has already been initialized!", "already_initialized")
+		}
 	}
 
-	return *result.NewSuccess("successfully initialized mig")
-}
-
-func postgresInit(dbox database.DbBox) error {
-	tx, err := dbox.Db.Begin()
-	if err != nil {
-		return err
+	created, changes, failed := initTables(dbox)
+	if failed != nil {
+		return *failed
 	}
 
-	defer tx.Rollback()
-
-	_, err = tx.Exec(`CREATE TABLE migrations (
-		id serial NOT NULL,
-		name varchar(255) NULL,
-		batch int4 NULL,
-		migration_time timestamptz NULL,
-		CONSTRAINT migrations_pkey PRIMARY KEY (id)
-	);`)
-	if err != nil {
-		return err
+	if created {
+		return *result.NewSuccess("successfully initialized mig")
 	}
 
-	_, err = tx.Exec(`CREATE TABLE migrations_lock (
-		"index" serial NOT NULL,
-		is_locked int4 NULL,
-		CONSTRAINT migrations_lock_pkey PRIMARY KEY (index)
-	);`)
-	if err != nil {
-		return err
+	if len(changes) == 0 {
+		return *result.NewSuccess("mig has already been initialized")
 	}
 
-	_, err = tx.Exec(`INSERT INTO migrations_lock ("index", is_locked) VALUES(1, 0);`)
-	if err != nil {
-		return err
+	res := result.NewSuccess(color.HiWhiteString("mig was already initialized but the tables were incomplete:"))
+	for _, change := range changes {
+		res.AddSuccessLn(color.GreenString("* %s", change))
 	}
 
-	if err = tx.Commit(); err != nil {
-		return err
+	return *res
+}
+
+// Whether either of the tables created by the first version of mig exist
+func isInitialized(dbox database.DbBox) (bool, error) {
+	for _, table := range []string{"migrations", "migrations_lock"} {
+		exists, err := dbox.TableExists(table)
+		if err != nil || exists {
+			return exists, err
+		}
 	}
 
-	return nil
+	return false, nil
 }
 
-func mysqlInit(dbox database.DbBox) error {
-	tx, err := dbox.Db.Begin()
+// Creates the tracking tables, or completes them when mig was already initialized, e.g. when an earlier
+// init was interrupted or the lock row was deleted. Tables created by an older version of mig are upgraded.
+// Returns whether the tables were created from scratch, otherwise the changes made to complete them.
+func initTables(dbox database.DbBox) (bool, []string, *result.Response) {
+	initialized, err := isInitialized(dbox)
 	if err != nil {
-		return err
+		return false, nil, result.NewErrorWithDetails("error initializing mig!", "unable_init", err)
 	}
 
-	defer tx.Rollback()
+	if !initialized {
+		if err = migrations.CreateTables(dbox); err != nil {
+			return false, nil, result.NewErrorWithDetails("error initializing mig!", "unable_init", err)
+		}
 
-	_, err = tx.Exec(`CREATE TABLE migrations (
-		id serial NOT NULL PRIMARY KEY,
-		name varchar(255) NULL,
-		batch int4 NULL,
-		migration_time TIMESTAMP NULL
-	);`)
-	if err != nil {
-		return err
+		return true, nil, nil
 	}
 
-	_, err = tx.Exec(`CREATE TABLE migrations_lock (
-		` + "`index`" + ` serial NOT NULL PRIMARY KEY,
-		is_locked int4 NULL
-	);`)
+	changes, err := migrations.RepairTables(dbox)
 	if err != nil {
-		return err
+		return false, changes, result.NewErrorWithDetails("error repairing the mig tables!", "unable_init", err)
 	}
 
-	_, err = tx.Exec(`INSERT INTO migrations_lock SET ` + "`index`" + ` = 1, is_locked = 0;`)
+	version, err := migrations.GetTablesVersion(dbox)
 	if err != nil {
-		return err
+		return false, changes, result.NewErrorWithDetails("Unable to determine the version of the mig tables!", "upgrade_tables", err)
 	}
 
-	if err = tx.Commit(); err != nil {
-		return err
+	if version >= migrations.SCHEMA_VERSION {
+		return false, changes, nil
 	}
 
-	return nil
-}
-
-func sqliteInit(dbox database.DbBox) error {
-	tx, err := dbox.Db.Begin()
+	locked, err := database.ObtainLock(dbox)
 	if err != nil {
-		return err
+		return false, changes, result.NewErrorWithDetails("Error obtaining lock for upgrading tables!", "obtain_lock", err)
 	}
-
-	defer tx.Rollback()
-
-	_, err = tx.Exec(`CREATE TABLE migrations (
-		id serial NOT NULL,
-		name varchar(255) NULL,
-		batch int4 NULL,
-		migration_time timestamp NULL,
-		CONSTRAINT migrations_pkey PRIMARY KEY (id)
-	);`)
-	if err != nil {
-		return err
+	if !locked {
+		return false, changes, result.NewError("Unable to obtain lock for upgrading tables!", "obtain_lock")
 	}
 
-	_, err = tx.Exec(`CREATE TABLE migrations_lock (
-		"index" serial NOT NULL,
-		is_locked int4 NULL,
-		CONSTRAINT migrations_lock_pkey PRIMARY KEY ("index")
-	);`)
-	if err != nil {
-		return err
-	}
+	upgrades, err := migrations.UpgradeTables(dbox)
+	changes = append(changes, upgrades...)
 
-	_, err = tx.Exec(`INSERT INTO migrations_lock ("index", is_locked) VALUES(1, 0);`)
-	if err != nil {
-		return err
-	}
+	database.ReleaseLock(dbox)
 
-	if err = tx.Commit(); err != nil {
-		return err
+	if err != nil {
+		return false, changes, result.NewErrorWithDetails("Unable to upgrade the mig tables!", "upgrade_tables", err)
 	}
 
-	return nil
+	return false, changes, nil
 }
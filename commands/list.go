@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/fatih/color"
@@ -11,7 +12,7 @@ import (
 )
 
 func CommandList(cfg config.MigConfig) result.Response {
-	dbox, err := database.Connect(cfg.Connection)
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
 	if err != nil {
 		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
 	}
@@ -23,18 +24,36 @@ func CommandList(cfg config.MigConfig) result.Response {
 		return *result.NewErrorWithDetails("unable to get migration status", "unable_get_status", err)
 	}
 
-	res := result.NewSerializable(color.WhiteString("%5s %-48s %5s %-20s %-20s", "ID", "Migration", "Batch", "Time of Run", "Note"), status.History)
+	runs, err := migrations.ListRuns(dbox)
+	if err != nil {
+		return *result.NewErrorWithDetails("unable to get migration history", "unable_get_status", err)
+	}
+
+	for i := range status.History {
+		status.History[i].Runs = runs[status.History[i].Migration.StoredName()]
+	}
+
+	res := result.NewSerializable(color.WhiteString("%5s %-48s %5s %-20s %10s %-32s %7s %-20s", "ID", "Migration", "Batch", "Time of Run", "Duration", "Run By", "Up/Down", "Note"), status.History)
 
 	for _, entry := range status.History {
+		migration := entry.Migration
+		updown := formatUpDown(entry.Runs)
+
 		switch entry.Status {
 		case "applied":
-			res.AddSuccessLn(color.GreenString("%5d %-48s %5d %20s %-20s", entry.Migration.Id, entry.Migration.Name, entry.Migration.Batch, entry.Migration.Time.Format(time.RFC3339), "Applied"))
+			note := "Applied"
+			if len(migration.Replaced) > 0 {
+				note = "Applied, Squashed"
+			} else if migration.RenamedFrom != "" {
+				note = "Applied, Renamed"
+			}
+			res.AddSuccessLn(color.GreenString("%5d %-48s %5d %20s %10s %-32s %7s %-20s", migration.Id, migration.Name, migration.Batch, migration.Time.Format(time.RFC3339), formatDuration(migration.DurationMs), formatRunBy(migration), updown, note))
 		case "skipped":
-			res.AddSuccessLn(color.RedString("%5s %-48s %5s %20s %-20s", "", entry.Migration.Name, "", "", "Migration Skipped!"))
+			res.AddSuccessLn(color.RedString("%5s %-48s %5s %20s %10s %-32s %7s %-20s", "", migration.Name, "", "", "", "", updown, "Migration Skipped!"))
 		case "missing":
-			res.AddSuccessLn(color.YellowString("%5d %-48s %5d %20s %-20s", entry.Migration.Id, entry.Migration.Name, entry.Migration.Batch, entry.Migration.Time.Format(time.RFC3339), "Missing File!"))
+			res.AddSuccessLn(color.YellowString("%5d %-48s %5d %20s %10s %-32s %7s %-20s", migration.Id, migration.Name, migration.Batch, migration.Time.Format(time.RFC3339), formatDuration(migration.DurationMs), formatRunBy(migration), updown, "Missing File!"))
 		case "unapplied":
-			res.AddSuccessLn(color.CyanString("%5s %-48s %5s %20s %-20s", "", entry.Migration.Name, "", "", "Unapplied"))
+			res.AddSuccessLn(color.CyanString("%5s %-48s %5s %20s %10s %-32s %7s %-20s", "", migration.Name, "", "", "", "", updown, "Unapplied"))
 		}
 	}
 
@@ -54,3 +73,38 @@ func CommandList(cfg config.MigConfig) result.Response {
 
 	return *res
 }
+
+// Migrations run by older versions of mig have no duration
+func formatDuration(durationMs *int64) string {
+	if durationMs == nil {
+		return ""
+	}
+
+	return (time.Duration(*durationMs) * time.Millisecond).String()
+}
+
+func formatRunBy(migration migrations.MigrationRow) string {
+	if migration.OsUser == "" || migration.Hostname == "" {
+		return migration.OsUser + migration.Hostname
+	}
+
+	return migration.OsUser + "@" + migration.Hostname
+}
+
+// The number of times a migration was run up and down, e.g. 2/1
+func formatUpDown(runs []migrations.MigrationRun) string {
+	if len(runs) == 0 {
+		return ""
+	}
+
+	up, down := 0, 0
+	for _, run := range runs {
+		if run.Direction == "down" {
+			down++
+		} else {
+			up++
+		}
+	}
+
+	return fmt.Sprintf("%d/%d", up, down)
+}
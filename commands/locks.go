@@ -7,7 +7,7 @@ import (
 )
 
 func CommandLock(cfg config.MigConfig) result.Response {
-	dbox, err := database.Connect(cfg.Connection)
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
 	if err != nil {
 		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
 	}
@@ -103,7 +103,7 @@ func sqliteLock(dbox database.DbBox) (bool, error) {
 }
 
 func CommandUnlock(cfg config.MigConfig) result.Response {
-	dbox, err := database.Connect(cfg.Connection)
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
 
 	if err != nil {
 		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
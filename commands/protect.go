@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"github.com/tlhunter/mig/config"
+	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/result"
+)
+
+// Commands which may destroy data or hide a failure when run against a protected connection.
+// There is no reset command, and migrations can't run out of order since skipped migrations are refused.
+var PROTECTED_COMMANDS = map[string]bool{
+	"down":           true,
+	"unlock":         true,
+	"rename-applied": true,
+}
+
+// Whether a command is destructive, some commands are only destructive with a flag
+func isProtectedCommand(cfg config.MigConfig, command string) bool {
+	return PROTECTED_COMMANDS[command] || (command == "doctor" && cfg.Fix)
+}
+
+// Ensures that the user really intends to run a destructive command against a protected connection.
+// Returns nil when the command may continue.
+func ConfirmProtected(cfg config.MigConfig, command string) *result.Response {
+	if !cfg.Protected || cfg.Confirmed || !isProtectedCommand(cfg, command) {
+		return nil
+	}
+
+	dbName, err := database.GetDatabaseName(cfg.Connection)
+	if err != nil {
+		return result.NewErrorWithDetails("unable to determine the name of the protected database", "bad_config", err)
+	}
+
+	if !isInteractive(cfg) {
+		res := result.NewError(fmt.Sprintf("Refusing to run `mig %s` against a protected connection!", command), "protected_connection")
+		res.AddErrorLn("Run the command from an interactive terminal or provide the --i-know-what-im-doing flag.")
+		return res
+	}
+
+	fmt.Fprintln(os.Stderr, color.RedString("The connection is protected and `mig %s` is a destructive command.", command))
+	fmt.Fprint(os.Stderr, color.WhiteString("Type the name of the database (%s) to continue: ", dbName))
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return result.NewErrorWithDetails("unable to read confirmation", "protected_connection", err)
+	}
+
+	if strings.TrimSpace(answer) != dbName {
+		return result.NewError("The database name didn't match, aborting.", "protected_connection")
+	}
+
+	return nil
+}
+
+// Whether the user can be prompted, which requires a terminal and human readable output.
+// A character device isn't enough since /dev/null is one too.
+func isInteractive(cfg config.MigConfig) bool {
+	fd := os.Stdin.Fd()
+
+	return !cfg.OutputJson && (isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd))
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"github.com/fatih/color"
+	"github.com/tlhunter/mig/config"
+	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/migrations"
+	"github.com/tlhunter/mig/result"
+)
+
+type RenamedMigration struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type CommandRenameAppliedResult struct {
+	Renamed []RenamedMigration `json:"renamed"`
+}
+
+// Stores the new names from the rename manifest in the tracking tables
+func CommandRenameApplied(cfg config.MigConfig) result.Response {
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
+	if err != nil {
+		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
+	}
+
+	defer dbox.Db.Close()
+
+	status, err := migrations.GetStatus(cfg, dbox)
+	if err != nil {
+		return *result.NewErrorWithDetails("Encountered an error trying to get migrations status!", "retrieve_status", err)
+	}
+
+	renamed := []RenamedMigration{}
+
+	var pending []migrations.MigrationRow
+	for _, entry := range status.History {
+		if entry.Migration.RenamedFrom != "" {
+			pending = append(pending, entry.Migration)
+		}
+	}
+
+	if len(pending) == 0 {
+		return *result.NewSerializable("There are no applied migrations to rename.", CommandRenameAppliedResult{Renamed: renamed})
+	}
+
+	locked, err := database.ObtainLock(dbox)
+	if err != nil {
+		return *result.NewErrorWithDetails("Error obtaining lock for renaming migrations!", "obtain_lock", err)
+	}
+	if !locked {
+		return *result.NewError("Unable to obtain lock for renaming migrations!", "obtain_lock")
+	}
+
+	res := result.NewSerializable(color.HiWhiteString("Renamed the applied migrations:"), CommandRenameAppliedResult{Renamed: renamed})
+
+	for _, migration := range pending {
+		if err = migrations.RenameMigration(dbox, migration.Id, migration.RenamedFrom, migration.Name); err != nil {
+			database.ReleaseLock(dbox)
+
+			res.SetError("Unable to rename the applied migration "+migration.RenamedFrom+"!", "rename_migration")
+			res.SetErrorDetails(err)
+			res.Serializable = nil
+
+			return *res
+		}
+
+		renamed = append(renamed, RenamedMigration{From: migration.RenamedFrom, To: migration.Name})
+		res.Serializable = CommandRenameAppliedResult{Renamed: renamed}
+		res.AddSuccessLn(color.GreenString("* %s -> %s", migration.RenamedFrom, migration.Name))
+	}
+
+	released, err := database.ReleaseLock(dbox)
+	if err != nil {
+		res.SetError("Error releasing lock after renaming migrations!", "release_lock")
+		return *res
+	}
+	if !released {
+		res.SetError("Unable to release lock after renaming migrations!", "release_lock")
+	}
+
+	return *res
+}
@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/tlhunter/mig/config"
+	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/migrations"
+	"github.com/tlhunter/mig/result"
+)
+
+type Roundtrip struct {
+	Migration   string                      `json:"migration"`
+	Code        string                      `json:"code"` // ok, up_failed, down_failed, down_incomplete, or rerun_failed
+	Details     string                      `json:"details,omitempty"`
+	Differences []database.SchemaDifference `json:"differences,omitempty"` // what the down block didn't restore
+}
+
+type CommandVerifyRoundtripResult struct {
+	Error      string      `json:"error,omitempty"`
+	ErrorCode  string      `json:"code,omitempty"`
+	Migrations []Roundtrip `json:"migrations"`
+}
+
+// Applies every migration to a scratch database, running the down block of each after its up block and
+// then the up block again, to find down blocks which don't reverse their up blocks before they're needed
+func CommandVerifyRoundtrip(cfg config.MigConfig) result.Response {
+	scratch, cleanup, failed := openScratch(cfg)
+	if failed != nil {
+		return *failed
+	}
+
+	defer cleanup()
+
+	scratchCfg := cfg
+	scratchCfg.Connection = scratch.Connection
+	scratchCfg.Init = true
+	scratchCfg.SingleTransaction = false
+
+	var roundtrips []Roundtrip
+
+	for {
+		before, err := scratch.DescribeSchema(migrations.TrackingTables())
+		if err != nil {
+			return *result.NewErrorWithDetails("Unable to introspect the scratch database!", "describe_schema", err)
+		}
+
+		up := CommandUp(scratchCfg)
+		if up.ErrorCode == "no_migrations" {
+			break
+		}
+
+		if up.ErrorCode != "" {
+			roundtrips = append(roundtrips, Roundtrip{
+				Migration: failedMigration(scratchCfg),
+				Code:      "up_failed",
+				Details:   responseDetails(up),
+			})
+			break
+		}
+
+		roundtrip := Roundtrip{Migration: up.Serializable.(CommandUpResult).Migration.Name, Code: "ok"}
+
+		if down := CommandDown(scratchCfg); down.ErrorCode != "" {
+			roundtrip.Code = "down_failed"
+			roundtrip.Details = responseDetails(down)
+			roundtrips = append(roundtrips, roundtrip)
+			break
+		}
+
+		restored, err := scratch.DescribeSchema(migrations.TrackingTables())
+		if err != nil {
+			return *result.NewErrorWithDetails("Unable to introspect the scratch database!", "describe_schema", err)
+		}
+
+		if differences := database.CompareSchemas(before, restored, true); len(differences) > 0 {
+			roundtrip.Code = "down_incomplete"
+			roundtrip.Differences = differences
+		}
+
+		if rerun := CommandUp(scratchCfg); rerun.ErrorCode != "" {
+			roundtrip.Code = "rerun_failed"
+			roundtrip.Details = responseDetails(rerun)
+			roundtrips = append(roundtrips, roundtrip)
+			break
+		}
+
+		roundtrips = append(roundtrips, roundtrip)
+	}
+
+	if roundtrips == nil {
+		roundtrips = []Roundtrip{}
+	}
+
+	var problems []Roundtrip
+	for _, roundtrip := range roundtrips {
+		if roundtrip.Code != "ok" {
+			problems = append(problems, roundtrip)
+		}
+	}
+
+	if len(problems) == 0 {
+		return *result.NewSerializable(fmt.Sprintf("The down blocks of all %d migrations reverse their up blocks.", len(roundtrips)), CommandVerifyRoundtripResult{
+			Migrations: roundtrips,
+		})
+	}
+
+	message := "Some migrations don't survive a roundtrip!"
+	res := result.NewError(message, "roundtrip_failed")
+	res.ExitStatus = 9
+
+	for _, problem := range problems {
+		switch problem.Code {
+		case "up_failed":
+			res.AddErrorLn(color.WhiteString("* %s: the up block failed: %s", problem.Migration, problem.Details))
+		case "down_failed":
+			res.AddErrorLn(color.WhiteString("* %s: the down block failed: %s", problem.Migration, problem.Details))
+		case "rerun_failed":
+			res.AddErrorLn(color.WhiteString("* %s: the up block failed when run again: %s", problem.Migration, problem.Details))
+		}
+
+		if len(problem.Differences) > 0 {
+			res.AddErrorLn(color.WhiteString("* %s: the down block didn't restore the schema", problem.Migration))
+			for _, difference := range problem.Differences {
+				res.AddErrorLn(color.WhiteString("  - %s", difference.Message))
+			}
+		}
+	}
+
+	if last := roundtrips[len(roundtrips)-1]; last.Code != "ok" && last.Code != "down_incomplete" {
+		res.AddErrorLn(color.WhiteString("The migrations after %s weren't verified.", last.Migration))
+
+		if last.Code != "rerun_failed" {
+			addScratchHint(res, cfg, "verify-roundtrip")
+		}
+	}
+
+	res.Serializable = CommandVerifyRoundtripResult{
+		Error:      message,
+		ErrorCode:  "roundtrip_failed",
+		Migrations: roundtrips,
+	}
+
+	return *res
+}
+
+// The migration which failed to apply, since a failed mig up doesn't return it
+func failedMigration(cfg config.MigConfig) string {
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
+	if err != nil {
+		return ""
+	}
+
+	defer dbox.Db.Close()
+
+	status, err := migrations.GetStatus(cfg, dbox)
+	if err != nil {
+		return ""
+	}
+
+	return status.Next
+}
+
+func responseDetails(res result.Response) string {
+	return strings.TrimSpace(res.Error + " " + res.Details)
+}
@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tlhunter/mig/config"
+	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/migrations"
+	"github.com/tlhunter/mig/result"
+)
+
+// Commands which dump the schema afterwards when --dump-schema is provided
+var DUMP_SCHEMA_COMMANDS = map[string]bool{
+	"up":   true,
+	"upto": true,
+	"all":  true,
+	"down": true,
+}
+
+type CommandDumpSchemaResult struct {
+	Filename string `json:"filename"`
+}
+
+// Writes the schema of the database to the schema file so that it can be reviewed and checked in
+func CommandDumpSchema(cfg config.MigConfig) result.Response {
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
+	if err != nil {
+		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
+	}
+
+	defer dbox.Db.Close()
+
+	if failed := dumpSchema(cfg, dbox); failed != nil {
+		return *failed
+	}
+
+	return *result.NewSerializable("Wrote the schema to "+cfg.SchemaFile, CommandDumpSchemaResult{
+		Filename: cfg.SchemaFile,
+	})
+}
+
+type CommandLoadSchemaResult struct {
+	Filename   string                    `json:"filename"`
+	Migrations []migrations.MigrationRow `json:"migrations"`
+}
+
+// Bootstraps an empty database from the schema file instead of running every migration
+func CommandLoadSchema(cfg config.MigConfig) result.Response {
+	dump, err := migrations.ReadSchema(cfg.SchemaFile)
+	if err != nil {
+		return *result.NewErrorWithDetails("Unable to read the schema file!", "read_schema", err)
+	}
+
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
+	if err != nil {
+		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
+	}
+
+	defer dbox.Db.Close()
+
+	tables, err := dbox.ListTables()
+	if err != nil {
+		return *result.NewErrorWithDetails("Unable to list the tables of the database!", "load_schema", err)
+	}
+
+	tracking := map[string]bool{}
+	for _, table := range migrations.TrackingTables() {
+		tracking[table] = true
+	}
+
+	var existing []string
+	for _, table := range tables {
+		if !tracking[table] {
+			existing = append(existing, table)
+		}
+	}
+
+	if len(existing) > 0 {
+		res := result.NewError("Refusing to load the schema into a database which already has tables!", "database_not_empty")
+		res.AddErrorLn(fmt.Sprintf("Found the tables: %s", strings.Join(existing, ", ")))
+		return *res
+	}
+
+	if _, _, failed := initTables(dbox); failed != nil {
+		return *failed
+	}
+
+	var count int
+	if err = dbox.QueryRow(migrations.COUNT).Scan(&count); err != nil {
+		return *result.NewErrorWithDetails("Unable to count the applied migrations!", "load_schema", err)
+	}
+
+	if count > 0 {
+		return *result.NewError("Refusing to load the schema into a database which already has applied migrations!", "database_not_empty")
+	}
+
+	locked, err := database.ObtainLock(dbox)
+	if err != nil {
+		return *result.NewErrorWithDetails("Error obtaining lock for loading the schema!", "obtain_lock", err)
+	}
+	if !locked {
+		return *result.NewError("Unable to obtain lock for loading the schema!", "obtain_lock")
+	}
+
+	loaded, err := migrations.LoadSchema(dbox, dump, cfg.Migrations, Version)
+	if err != nil {
+		database.ReleaseLock(dbox)
+		return *result.NewErrorWithDetails("Unable to load the schema!", "load_schema", err)
+	}
+
+	if loaded == nil {
+		loaded = []migrations.MigrationRow{}
+	}
+
+	res := result.NewSerializable(fmt.Sprintf("Loaded the schema from %s and recorded %d migrations.", cfg.SchemaFile, len(loaded)), CommandLoadSchemaResult{
+		Filename:   cfg.SchemaFile,
+		Migrations: loaded,
+	})
+
+	released, err := database.ReleaseLock(dbox)
+	if err != nil {
+		res.SetError("Error releasing lock after loading the schema!", "release_lock")
+		return *res
+	}
+	if !released {
+		res.SetError("Unable to release lock after loading the schema!", "release_lock")
+	}
+
+	return *res
+}
+
+func dumpSchema(cfg config.MigConfig, dbox database.DbBox) *result.Response {
+	status, err := migrations.GetStatus(cfg, dbox)
+	if err != nil {
+		return result.NewErrorWithDetails("Encountered an error trying to get migrations status!", "retrieve_status", err)
+	}
+
+	dump, err := migrations.DumpSchema(dbox, status)
+	if err != nil {
+		return result.NewErrorWithDetails("Unable to introspect the schema!", "dump_schema", err)
+	}
+
+	if err = os.WriteFile(cfg.SchemaFile, []byte(dump), 0644); err != nil {
+		return result.NewErrorWithDetails("Unable to write the schema file!", "dump_schema", err)
+	}
+
+	return nil
+}
+
+// Dumps the schema after a command which migrated successfully, the migration result is kept either way
+func dumpSchemaAfter(cfg config.MigConfig, res *result.Response) {
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
+	if err != nil {
+		res.SetError("Migrated but unable to connect to dump the schema!", "dump_schema")
+		res.SetErrorDetails(err)
+		return
+	}
+
+	defer dbox.Db.Close()
+
+	if failed := dumpSchema(cfg, dbox); failed != nil {
+		res.SetErrorFrom(failed)
+		return
+	}
+
+	res.AddSuccessLn("Wrote the schema to " + cfg.SchemaFile)
+}
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/fatih/color"
+	"github.com/tlhunter/mig/result"
+)
+
+// Exit status used when a second interrupt forces mig to exit
+const FORCED_EXIT_STATUS = 130
+
+// interruptWatcher tracks SIGINT and SIGTERM while mig holds the migration lock.
+// The first signal cancels Ctx so that the current migration is rolled back, or allowed to finish
+// when it can't be rolled back, and no further migrations are started.
+// A second signal exits the process immediately.
+type interruptWatcher struct {
+	Ctx     context.Context
+	cancel  context.CancelFunc
+	signals chan os.Signal
+	done    chan struct{}
+}
+
+func watchInterrupts() *interruptWatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	watcher := &interruptWatcher{
+		Ctx:     ctx,
+		cancel:  cancel,
+		signals: make(chan os.Signal, 2),
+		done:    make(chan struct{}),
+	}
+
+	signal.Notify(watcher.signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for {
+			select {
+			case <-watcher.done:
+				return
+			case <-watcher.signals:
+			}
+
+			if ctx.Err() == nil {
+				fmt.Fprintln(os.Stderr, color.YellowString("Interrupt received! Stopping once the current migration is rolled back or finished."))
+				fmt.Fprintln(os.Stderr, color.YellowString("Interrupt again to exit immediately."))
+				cancel()
+				continue
+			}
+
+			fmt.Fprintln(os.Stderr, color.RedString("Exiting immediately! The migration lock is likely still held, see `mig status`."))
+			os.Exit(FORCED_EXIT_STATUS)
+		}
+	}()
+
+	return watcher
+}
+
+func (w *interruptWatcher) Interrupted() bool {
+	return w.Ctx.Err() != nil
+}
+
+// Restores the default signal behavior
+func (w *interruptWatcher) Stop() {
+	signal.Stop(w.signals)
+	close(w.done)
+	w.cancel()
+}
+
+// Explains on the response that a run of migrations stopped early because of an interrupt
+func addInterruptedSummary(res *result.Response, applied int) {
+	if res.ErrorCode == "" {
+		res.SetError("Interrupted! The remaining migrations were not executed.", "migration_interrupted")
+	}
+
+	res.AddErrorLn(fmt.Sprintf("Migrations applied before stopping: %d", applied))
+}
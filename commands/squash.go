@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"github.com/fatih/color"
+	"github.com/tlhunter/mig/config"
+	"github.com/tlhunter/mig/migrations"
+	"github.com/tlhunter/mig/result"
+)
+
+// Combines every migration up to and including the target into a single migration
+func CommandSquash(cfg config.MigConfig, target string) result.Response {
+	squashed, err := migrations.Squash(cfg.Migrations, target, cfg.SchemaFile)
+	if err != nil {
+		return *result.NewErrorWithDetails("Unable to squash the migrations!", "unable_squash", err)
+	}
+
+	res := result.NewSerializable(color.HiWhiteString("Squashed %d migrations into %s", len(squashed.Replaces), squashed.Filename), squashed)
+	res.AddSuccessLn(color.WhiteString("The original migrations were moved to the %s directory.", migrations.ARCHIVE_DIR))
+
+	return *res
+}
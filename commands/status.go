@@ -11,45 +11,6 @@ import (
 	"github.com/tlhunter/mig/result"
 )
 
-var EXIST_MIGRATIONS = database.QueryBox{
-	Postgres: `SELECT EXISTS (
-SELECT FROM
-	pg_tables
-WHERE
-	schemaname = 'public' AND
-	tablename  = 'migrations'
-) AS table_exists;`,
-	Mysql:  `CALL sys.table_exists(DATABASE(), 'migrations', @table_type); SELECT @table_type LIKE 'BASE TABLE';`,
-	Sqlite: `SELECT COUNT(name) >= 1 AS table_is_present FROM sqlite_master WHERE type='table' AND name='migrations';`,
-}
-
-var EXIST_LOCK = database.QueryBox{
-	Postgres: `SELECT EXISTS (
-	SELECT FROM
-		pg_tables
-	WHERE
-		schemaname = 'public' AND
-		tablename  = 'migrations_lock'
-	) AS table_exists;`,
-	Mysql:  `CALL sys.table_exists(DATABASE(), 'migrations_lock', @table_type); SELECT @table_type LIKE 'BASE TABLE';`,
-	Sqlite: `SELECT COUNT(name) >= 1 AS table_is_present FROM sqlite_master WHERE type='table' AND name='migrations_lock';`,
-}
-
-var DESCRIBE = database.QueryBox{
-	Postgres: `SELECT
-	table_name,
-	column_name,
-	data_type
-FROM
-	information_schema.columns
-WHERE
-	table_name = 'migrations' OR table_name = 'migrations_lock'
-ORDER BY
-	table_name, column_name;`,
-	Mysql:  `DESC migrations;`,                 // unused
-	Sqlite: `pragma table_info('migrations');`, // unused
-}
-
 var LOCK_STATUS = database.QueryBox{
 	Postgres: `SELECT is_locked FROM migrations_lock WHERE index = 1;`,
 	Mysql:    `SELECT is_locked FROM migrations_lock WHERE ` + "`index`" + ` = 1;`,
@@ -57,8 +18,16 @@ var LOCK_STATUS = database.QueryBox{
 }
 
 type StatusResponse struct {
-	Locked bool `json:"locked"`
-	Status any  `json:"status"`
+	Locked         bool `json:"locked"`
+	Status         any  `json:"status"`
+	TablesVersion  int  `json:"tables_version"`
+	TablesOutdated bool `json:"tables_outdated"`
+}
+
+type StatusProblemsResponse struct {
+	Error     string                    `json:"error"`
+	ErrorCode string                    `json:"code"`
+	Problems  []migrations.TableProblem `json:"problems"`
 }
 
 // Provide a narrative to the user about the current status of mig
@@ -67,23 +36,19 @@ func CommandStatus(cfg config.MigConfig) result.Response {
 
 	// Attempt to connect to database
 
-	dbox, err := database.Connect(cfg.Connection)
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
 	if err != nil {
 		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
 	}
 
 	// Check if migration tables exist
 
-	existMigrations := false
-
-	err = dbox.QueryRow(EXIST_MIGRATIONS).Scan(&existMigrations)
+	existMigrations, err := dbox.TableExists("migrations")
 	if err != nil {
 		return *result.NewErrorWithDetails("unable to tell if 'migrations' table exists!", "unable_check_migrations", err)
 	}
 
-	existLock := false
-
-	err = dbox.QueryRow(EXIST_LOCK).Scan(&existLock)
+	existLock, err := dbox.TableExists("migrations_lock")
 	if err != nil {
 		return *result.NewErrorWithDetails("unable to tell if 'migrations_lock' table exists!", "unable_check_migrations_lock", err)
 	}
@@ -104,8 +69,7 @@ func CommandStatus(cfg config.MigConfig) result.Response {
 		res.ExitStatus = 9
 		res.AddErrorLn("This might mean that data has been corrupted and that migration status is missing.")
 		res.AddErrorLn("Consider looking into the root cause of the problem.")
-		res.AddErrorLn("The quickest fix is to delete the lock table and initialize again:")
-		res.AddErrorLn("> DROP TABLE migrations_lock;")
+		res.AddErrorLn("The quickest fix is to initialize again, which creates an empty migrations table:")
 		res.AddErrorLn("$ mig init")
 		return res
 	}
@@ -115,70 +79,59 @@ func CommandStatus(cfg config.MigConfig) result.Response {
 		res.ExitStatus = 9
 		res.AddErrorLn("This might mean that data has been corrupted.")
 		res.AddErrorLn("Consider looking into the cause of the problem.")
-		res.AddErrorLn("The quickest fix is backup the migrations table data, initialize again, then restore the data:")
-		res.AddErrorLn("> ALTER TABLE migrations RENAME TO migrations_backup;")
+		res.AddErrorLn("The quickest fix is to initialize again, which recreates the lock table and keeps the migrations table:")
 		res.AddErrorLn("$ mig init")
-		res.AddErrorLn("> DROP TABLE migrations;")
-		res.AddErrorLn("> ALTER TABLE migrations_backup RENAME TO migrations;")
 		return res
 	}
 
 	res := result.NewSerializable("", "")
 
-	if dbox.IsMysql {
-		// TODO: rebuild the gnarly checks
-		res.AddSuccessLn(color.YellowString("migration table description check is currently unimplemented for mysql."))
-	} else if dbox.IsSqlite {
-		// TODO: rebuild the gnarly checks
-		res.AddSuccessLn(color.YellowString("migration table description check is currently unimplemented for sqlite."))
-	} else if dbox.IsPostgres {
-		rows, err := dbox.Query(DESCRIBE)
-		if err != nil {
-			return *result.NewErrorWithDetails("unable to describe the migration tables!", "unable_describe", err)
-		}
+	// Check if the tables were created by an older version of mig
 
-		// Check if tables have correct columns
+	tablesVersion, err := migrations.GetTablesVersion(dbox)
+	if err != nil {
+		return *result.NewErrorWithDetails("unable to determine the version of the mig tables!", "unable_check_tables_version", err)
+	}
 
-		var table, column, data string
+	if tablesVersion < migrations.SCHEMA_VERSION {
+		res.AddSuccessLn(color.YellowString("The mig tables are at version %d but this version of mig uses version %d.", tablesVersion, migrations.SCHEMA_VERSION))
+		res.AddSuccessLn(color.WhiteString("They're upgraded automatically by the next migration, or right away by running the following:"))
+		res.AddSuccessLn(color.WhiteString("$ mig upgrade-tables"))
+		res.AddSuccessLn("")
+	} else if tablesVersion > migrations.SCHEMA_VERSION {
+		res.AddSuccessLn(color.RedString("The mig tables are at version %d which is newer than version %d used by this version of mig!", tablesVersion, migrations.SCHEMA_VERSION))
+		res.AddSuccessLn(color.WhiteString("Upgrade mig before running migrations."))
+		res.AddSuccessLn("")
+	}
 
-		// struggling to find the Go-way to do this...
-		rows.Next()
-		rows.Scan(&table, &column, &data)
-		if table != "migrations" || column != "batch" || data != "integer" {
-			return *result.NewError("expected migrations.batch of type integer", "invalid_batch_type")
-		}
+	// Check if the tables have the expected columns, types, primary keys, and lock row
 
-		rows.Next()
-		rows.Scan(&table, &column, &data)
-		if table != "migrations" || column != "id" || data != "integer" {
-			return *result.NewError("expected migrations.id of type integer", "invalid_id_type")
-		}
+	checkVersion := tablesVersion
+	if checkVersion > migrations.SCHEMA_VERSION {
+		checkVersion = migrations.SCHEMA_VERSION
+	}
 
-		rows.Next()
-		rows.Scan(&table, &column, &data)
-		if table != "migrations" || column != "migration_time" || data != "timestamp with time zone" {
-			return *result.NewError("expected migrations.migration_time of type timestamp with time zone", "invalid_time_type")
-		}
+	problems, err := migrations.CheckTables(dbox, checkVersion)
+	if err != nil {
+		return *result.NewErrorWithDetails("unable to inspect the mig tables!", "unable_describe", err)
+	}
 
-		rows.Next()
-		rows.Scan(&table, &column, &data)
-		if table != "migrations" || column != "name" || data != "character varying" {
-			return *result.NewError("expected migrations.name of type character varying", "invalid_name_type")
-		}
+	if len(problems) > 0 {
+		message := fmt.Sprintf("Found %d problem(s) with the tables used by mig!", len(problems))
 
-		rows.Next()
-		rows.Scan(&table, &column, &data)
-		if table != "migrations_lock" || column != "index" || data != "integer" {
-			return *result.NewError("expected migrations_lock.index of type integer", "invalid_index_type")
+		res := result.NewError(message, "invalid_tables")
+		res.ExitStatus = 9
+		res.Serializable = StatusProblemsResponse{
+			Error:     message,
+			ErrorCode: "invalid_tables",
+			Problems:  problems,
 		}
 
-		rows.Next()
-		rows.Scan(&table, &column, &data)
-		if table != "migrations_lock" || column != "is_locked" || data != "integer" {
-			return *result.NewError("expected migrations_lock.is_locked of type integer", "invalid_locked_type")
+		for _, problem := range problems {
+			res.AddErrorLn(color.WhiteString("* [%s] %s", problem.Code, problem.Message))
 		}
-	} else {
-		panic("unknown database: " + dbox.Type)
+
+		return *res
 	}
 
 	// Check if locked
@@ -211,8 +164,10 @@ func CommandStatus(cfg config.MigConfig) result.Response {
 		status.History = nil // omit for status command, it's still present for list command
 
 		res.Serializable = StatusResponse{
-			Status: status,
-			Locked: locked,
+			Status:         status,
+			Locked:         locked,
+			TablesVersion:  tablesVersion,
+			TablesOutdated: tablesVersion < migrations.SCHEMA_VERSION,
 		}
 
 		if status.Skipped > 0 {
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/tlhunter/mig/config"
 	"github.com/tlhunter/mig/database"
@@ -19,13 +20,20 @@ type CommandUpResult struct {
 }
 
 func CommandUp(cfg config.MigConfig) result.Response {
-	dbox, err := database.Connect(cfg.Connection)
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
 	if err != nil {
 		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
 	}
 
 	defer dbox.Db.Close()
 
+	// lets a container entrypoint initialize and migrate a fresh database in one step
+	if cfg.Init {
+		if _, _, failed := initTables(dbox); failed != nil {
+			return *failed
+		}
+	}
+
 	status, err := migrations.GetStatus(cfg, dbox)
 	if err != nil {
 		return *result.NewErrorWithDetails("Encountered an error trying to get migrations status!", "retrieve_status", err)
@@ -46,6 +54,14 @@ func CommandUp(cfg config.MigConfig) result.Response {
 		return *result.NewErrorWithDetails("Error attempting to read next migration file!", "read_next_migration", err)
 	}
 
+	// watching begins before locking so that an interrupt can't leave the lock behind
+	interrupts := watchInterrupts()
+	defer interrupts.Stop()
+
+	if failed := checkDirectives(cfg, dbox, next, queries, status, false); failed != nil {
+		return *failed
+	}
+
 	locked, err := database.ObtainLock(dbox)
 	if err != nil {
 		return *result.NewErrorWithDetails("Error obtaining lock for migration!", "obtain_lock", err)
@@ -54,19 +70,31 @@ func CommandUp(cfg config.MigConfig) result.Response {
 		return *result.NewError("Unable to obtain lock for migration!", "obtain_lock")
 	}
 
-	err = dbox.ExecMaybeTx(queries.Up, queries.UpTx)
-	if err != nil {
-		return *result.NewErrorWithDetails("Encountered an error while running migration!", "migration_failed", err)
+	if failed := upgradeTables(dbox); failed != nil {
+		return *failed
 	}
 
-	migration, err := migrations.AddMigration(dbox, next)
-	if err != nil {
-		res := result.NewErrorWithDetails("The migration query executed but unable to track it in the migrations table!", "untracked_migration", err)
-		res.AddErrorLn("You may want to manually add it and investigate the error.")
-		return *res
+	var migration migrations.MigrationRow
+
+	ran, failed := execMigration(interrupts.Ctx, cfg, dbox, next, queries, false, "Encountered an error while running migration!", func(box database.DbBox, elapsed time.Duration) (err error) {
+		migration, err = migrations.AddMigration(box, next, migrations.NewExecution(elapsed, Version, queries.Checksum))
+		return err
+	})
+	if failed != nil {
+		if failed.ErrorCode == "migration_interrupted" {
+			// the migration was rolled back so the tracking tables are consistent and the lock can be released
+			database.ReleaseLock(dbox)
+		}
+
+		return *failed
+	}
+
+	message := fmt.Sprintf("Migration %s was successfully applied!", next)
+	if !ran {
+		message = fmt.Sprintf("Migration %s doesn't run in the %s environment and was only recorded.", next, cfg.Environment)
 	}
 
-	res := result.NewSerializable(fmt.Sprintf("Migration %s was successfully applied!", next), CommandUpResult{
+	res := result.NewSerializable(message, CommandUpResult{
 		MigrationBatch: migration.Batch,
 		Migration:      &migration,
 	})
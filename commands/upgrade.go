@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"github.com/fatih/color"
+	"github.com/tlhunter/mig/config"
+	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/migrations"
+	"github.com/tlhunter/mig/result"
+)
+
+type CommandUpgradeTablesResult struct {
+	FromVersion int      `json:"from_version"`
+	ToVersion   int      `json:"to_version"`
+	Upgrades    []string `json:"upgrades"`
+}
+
+// Brings the tracking tables created by an older version of mig up to date
+func CommandUpgradeTables(cfg config.MigConfig) result.Response {
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
+	if err != nil {
+		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
+	}
+
+	defer dbox.Db.Close()
+
+	version, err := migrations.GetTablesVersion(dbox)
+	if err != nil {
+		return *result.NewErrorWithDetails("Unable to determine the version of the mig tables!", "upgrade_tables", err)
+	}
+
+	if version > migrations.SCHEMA_VERSION {
+		return *result.NewError("The mig tables were created by a newer version of mig! Upgrade mig instead.", "tables_too_new")
+	}
+
+	if version == migrations.SCHEMA_VERSION {
+		return *result.NewSerializable(color.GreenString("The mig tables are already up to date at version %d.", version), CommandUpgradeTablesResult{
+			FromVersion: version,
+			ToVersion:   version,
+			Upgrades:    []string{},
+		})
+	}
+
+	locked, err := database.ObtainLock(dbox)
+	if err != nil {
+		return *result.NewErrorWithDetails("Error obtaining lock for upgrading tables!", "obtain_lock", err)
+	}
+	if !locked {
+		return *result.NewError("Unable to obtain lock for upgrading tables!", "obtain_lock")
+	}
+
+	upgrades, err := migrations.UpgradeTables(dbox)
+	if err != nil {
+		database.ReleaseLock(dbox)
+		return *result.NewErrorWithDetails("Unable to upgrade the mig tables!", "upgrade_tables", err)
+	}
+
+	res := result.NewSerializable(color.HiWhiteString("Upgraded the mig tables from version %d to %d:", version, migrations.SCHEMA_VERSION), CommandUpgradeTablesResult{
+		FromVersion: version,
+		ToVersion:   migrations.SCHEMA_VERSION,
+		Upgrades:    upgrades,
+	})
+
+	for _, upgrade := range upgrades {
+		res.AddSuccessLn(color.GreenString("* %s", upgrade))
+	}
+
+	released, err := database.ReleaseLock(dbox)
+	if err != nil {
+		res.SetError("Error releasing lock after upgrading tables!", "release_lock")
+		return *res
+	}
+	if !released {
+		res.SetError("Unable to release lock after upgrading tables!", "release_lock")
+	}
+
+	return *res
+}
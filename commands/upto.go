@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/fatih/color"
 
@@ -20,7 +21,7 @@ type CommandUptoResult struct {
 //       would be nice to support "TIME_foo" or "foo" if unambiguous
 
 func CommandUpto(cfg config.MigConfig, target string) result.Response {
-	dbox, err := database.Connect(cfg.Connection)
+	dbox, err := database.Connect(cfg.Connection, cfg.ConnectOptions())
 	if err != nil {
 		return *result.NewErrorWithDetails("database connection error", "db_conn", err)
 	}
@@ -66,6 +67,10 @@ func CommandUpto(cfg config.MigConfig, target string) result.Response {
 		return *result.NewError(fmt.Sprintf("Unable to find an unexecuted upcoming migration named %s", target), "cannot_find_migration")
 	}
 
+	// watching begins before locking so that an interrupt can't leave the lock behind
+	interrupts := watchInterrupts()
+	defer interrupts.Stop()
+
 	locked, err := database.ObtainLock(dbox)
 	if err != nil {
 		return *result.NewErrorWithDetails("Error obtaining lock for migration!", "obtain_lock", err)
@@ -74,6 +79,10 @@ func CommandUpto(cfg config.MigConfig, target string) result.Response {
 		return *result.NewError("Unable to obtain lock for migration!", "obtain_lock")
 	}
 
+	if failed := upgradeTables(dbox); failed != nil {
+		return *failed
+	}
+
 	highest, err := migrations.GetHighestValues(dbox)
 	if err != nil {
 		return *result.NewErrorWithDetails("Unable to determine highest migration!", "unable_determine_highest", err)
@@ -88,6 +97,10 @@ func CommandUpto(cfg config.MigConfig, target string) result.Response {
 	})
 
 	for {
+		if interrupts.Interrupted() {
+			break
+		}
+
 		status, err := migrations.GetStatus(cfg, dbox)
 		if err != nil {
 			return *result.NewErrorWithDetails("Unable to get migration status!", "retrieve_status", err)
@@ -106,19 +119,38 @@ func CommandUpto(cfg config.MigConfig, target string) result.Response {
 			return *result.NewErrorWithDetails("Error attempting to read next migration file!", "read_next_migration", err)
 		}
 
-		err = dbox.ExecMaybeTx(queries.Up, queries.UpTx)
-		if err != nil {
-			return *result.NewErrorWithDetails("Encountered an error while running migration!", "migration_failed", err)
+		if failed := checkDirectives(cfg, dbox, next, queries, status, false); failed != nil {
+			// nothing was executed so the lock can be released
+			res.SetErrorFrom(failed)
+			break
 		}
 
-		res.AddSuccessLn(color.GreenString("Migration %s was successfully applied!", next))
+		var migration migrations.MigrationRow
+
+		ran, failed := execMigration(interrupts.Ctx, cfg, dbox, next, queries, false, "Encountered an error while running migration!", func(box database.DbBox, elapsed time.Duration) (err error) {
+			migration, err = migrations.AddMigrationWithBatch(box, next, batchId, migrations.NewExecution(elapsed, Version, queries.Checksum))
+			return err
+		})
+		if failed != nil {
+			if failed.ErrorCode == "untracked_migration" {
+				res.SetErrorFrom(failed)
+				res.AddErrorLn("Any remaining migrations will not be executed!")
+				return *res
+			}
 
-		migration, err := migrations.AddMigrationWithBatch(dbox, next, batchId)
-		if err != nil {
-			res.SetError("The migration query executed but unable to track it in the migrations table!", "untracked_migration")
-			res.AddErrorLn("You may want to manually add it and investigate the error.")
-			res.AddErrorLn("Any remaining migrations will not be executed!")
-			return *res
+			if failed.ErrorCode != "migration_interrupted" {
+				return *failed
+			}
+
+			// the migration was rolled back so the tracking tables are consistent and the lock can be released
+			res.SetErrorFrom(failed)
+			break
+		}
+
+		if ran {
+			res.AddSuccessLn(color.GreenString("Migration %s was successfully applied!", next))
+		} else {
+			res.AddSuccessLn(color.YellowString("Migration %s doesn't run in the %s environment and was only recorded.", next, cfg.Environment))
 		}
 
 		executedMigrations = append(executedMigrations, migration)
@@ -127,6 +159,10 @@ func CommandUpto(cfg config.MigConfig, target string) result.Response {
 		}
 	}
 
+	if interrupts.Interrupted() {
+		addInterruptedSummary(res, len(executedMigrations))
+	}
+
 	released, err := database.ReleaseLock(dbox)
 	if err != nil {
 		res.SetError("Error releasing lock after running migration!", "release_lock")
@@ -1,41 +1,123 @@
 package config
 
-import "github.com/tlhunter/mig/result"
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/logging"
+	"github.com/tlhunter/mig/result"
+)
 
 const (
-	DEF_MIG_DIR = "./migrations"
+	DEF_MIG_DIR     = "./migrations"
+	DEF_SCHEMA_FILE = "./schema.sql"
 )
 
 type MigConfig struct {
-	Connection string // DB connection string
+	Connection string // DB connection string, including the resolved password
 	Migrations string // migrations directory, e.g. ./migrations
 	MigRcPath  string // override path to config file
 	OutputJson bool   // stdout should be valid JSON
+	Protected  bool   // destructive commands require confirmation
+	Confirmed  bool   // skip the confirmation prompt for protected connections, e.g. in CI
+
+	Environment string // name of the environment, e.g. prod, used by the env directive
+
+	ConnectRetries int           // additional connection attempts, e.g. while a database boots
+	ConnectTimeout time.Duration // overall time limit for connecting, zero for no limit
+
+	Timeout          time.Duration // time limit for running a single migration, zero for no limit
+	LockTimeout      time.Duration // session limit for waiting on a database lock
+	StatementTimeout time.Duration // session limit for a single statement
+
+	TxSettings        database.TxSettings // isolation level and flags of migration transactions, overridden by directives
+	SingleTransaction bool                // mig all runs the whole batch in one transaction
+
+	Strict bool // mig init fails when mig has already been initialized
+	Init   bool // mig up and mig all initialize the tables first
+	Fix    bool // mig doctor applies every fix without prompting
+
+	SchemaFile string // path of the schema dump, e.g. ./schema.sql
+	DumpSchema bool   // mig up, upto, all, and down dump the schema after migrating
+
+	Scratch string // disposable database used by mig diff and mig verify-roundtrip, a temporary sqlite database when empty
+
+	Logging logging.Settings // level, format, and destination of the logs written to stderr
+}
+
+func (cfg MigConfig) ConnectOptions() database.ConnectOptions {
+	return database.ConnectOptions{
+		Retries: cfg.ConnectRetries,
+		Timeout: cfg.ConnectTimeout,
+
+		LockTimeout:      cfg.LockTimeout,
+		StatementTimeout: cfg.StatementTimeout,
+	}
 }
 
 func GetConfig() (MigConfig, []string, *result.Response) {
 	config := MigConfig{}
 
-	flagConfig, subcommands, _ := GetConfigFromProcessFlags()
+	flagConfig, subcommands, err := GetConfigFromProcessFlags()
+
+	if err != nil {
+		return config, subcommands, result.NewErrorWithDetails("unable to parse flags", "command_usage", err)
+	}
 
 	config.OutputJson = flagConfig.OutputJson
+	config.Confirmed = flagConfig.Confirmed
+	config.SingleTransaction = flagConfig.SingleTransaction
+	config.Strict = flagConfig.Strict
+	config.Init = flagConfig.Init
+	config.Fix = flagConfig.Fix
 
-	err := SetEnvFromConfigFile(flagConfig.MigRcPath) // reads .env and sets env vars but does not override
+	err = SetEnvFromConfigFile(flagConfig.MigRcPath) // reads .env and sets env vars but does not override
 
 	if err != nil {
 		return config, []string{}, nil
 	}
 
-	envConfig, _ := GetConfigFromEnvVars()
+	envConfig, err := GetConfigFromEnvVars()
+
+	if err != nil {
+		return config, subcommands, result.NewErrorWithDetails("unable to parse environment variables", "bad_config", err)
+	}
+
+	secrets := GetSecretsFromEnvVars()
+
+	fileConnection, err := secrets.ReadConnection()
+	if err != nil {
+		return config, subcommands, result.NewErrorWithDetails("unable to read connection file", "bad_config", err)
+	}
 
 	if flagConfig.Connection != "" {
 		config.Connection = flagConfig.Connection
 	} else if envConfig.Connection != "" {
 		config.Connection = envConfig.Connection
+	} else if fileConnection != "" {
+		config.Connection = fileConnection
+	} else if settings := GetSettingsFromEnvVars(); !settings.IsEmpty() {
+		config.Connection, err = settings.Url()
+		if err != nil {
+			return config, subcommands, result.NewErrorWithDetails("unable to assemble connection from settings", "bad_config", err)
+		}
 	} else {
 		return config, subcommands, result.NewError("unable to determine server connection", "bad_config")
 	}
 
+	password, err := secrets.ReadPassword()
+	if err != nil {
+		return config, subcommands, result.NewErrorWithDetails("unable to read connection password", "bad_config", err)
+	}
+
+	if password != "" {
+		config.Connection, err = MergePassword(config.Connection, password)
+		if err != nil {
+			return config, subcommands, result.NewErrorWithDetails("unable to add password to connection", "bad_config", err)
+		}
+	}
+
 	if flagConfig.Migrations != "" {
 		config.Migrations = flagConfig.Migrations
 	} else if envConfig.Migrations != "" {
@@ -44,5 +126,69 @@ func GetConfig() (MigConfig, []string, *result.Response) {
 		config.Migrations = DEF_MIG_DIR
 	}
 
+	if flagConfig.SchemaFile != "" {
+		config.SchemaFile = flagConfig.SchemaFile
+	} else if envConfig.SchemaFile != "" {
+		config.SchemaFile = envConfig.SchemaFile
+	} else {
+		config.SchemaFile = DEF_SCHEMA_FILE
+	}
+
+	config.DumpSchema = flagConfig.DumpSchema || envConfig.DumpSchema
+
+	config.Logging = envConfig.Logging
+	if flagConfig.Logging.Level != "" {
+		config.Logging.Level = flagConfig.Logging.Level
+	}
+	if flagConfig.Logging.Format != "" {
+		config.Logging.Format = flagConfig.Logging.Format
+	}
+	if flagConfig.Logging.File != "" {
+		config.Logging.File = flagConfig.Logging.File
+	}
+
+	if flagConfig.Scratch != "" {
+		config.Scratch = flagConfig.Scratch
+	} else {
+		config.Scratch = envConfig.Scratch
+	}
+
+	if flagConfig.ConnectRetries != 0 {
+		config.ConnectRetries = flagConfig.ConnectRetries
+	} else {
+		config.ConnectRetries = envConfig.ConnectRetries
+	}
+
+	config.ConnectTimeout = firstNonZeroDuration(flagConfig.ConnectTimeout, envConfig.ConnectTimeout)
+	config.Timeout = firstNonZeroDuration(flagConfig.Timeout, envConfig.Timeout)
+	config.LockTimeout = firstNonZeroDuration(flagConfig.LockTimeout, envConfig.LockTimeout)
+	config.StatementTimeout = firstNonZeroDuration(flagConfig.StatementTimeout, envConfig.StatementTimeout)
+
+	config.TxSettings = envConfig.TxSettings
+	if flagConfig.TxSettings.Isolation != sql.LevelDefault {
+		config.TxSettings.Isolation = flagConfig.TxSettings.Isolation
+	}
+	config.TxSettings.ReadOnly = flagConfig.TxSettings.ReadOnly || envConfig.TxSettings.ReadOnly
+	config.TxSettings.Deferrable = flagConfig.TxSettings.Deferrable || envConfig.TxSettings.Deferrable
+
+	if flagConfig.Environment != "" {
+		config.Environment = flagConfig.Environment
+	} else {
+		config.Environment = envConfig.Environment
+	}
+
+	// a connection can be marked as protected but never unmarked by a flag
+	config.Protected = flagConfig.Protected || envConfig.Protected
+
 	return config, subcommands, nil
 }
+
+func firstNonZeroDuration(durations ...time.Duration) time.Duration {
+	for _, d := range durations {
+		if d != 0 {
+			return d
+		}
+	}
+
+	return 0
+}
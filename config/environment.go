@@ -1,10 +1,40 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/logging"
+)
 
 const (
 	CONNECTION = "MIG_CONNECTION"
 	MIGRATIONS = "MIG_MIGRATIONS"
+	PROTECTED  = "MIG_PROTECTED"
+	ENV        = "MIG_ENV"
+
+	CONNECT_RETRIES = "MIG_CONNECT_RETRIES"
+	CONNECT_TIMEOUT = "MIG_CONNECT_TIMEOUT"
+
+	TIMEOUT           = "MIG_TIMEOUT"
+	LOCK_TIMEOUT      = "MIG_LOCK_TIMEOUT"
+	STATEMENT_TIMEOUT = "MIG_STATEMENT_TIMEOUT"
+
+	ISOLATION  = "MIG_ISOLATION"
+	READ_ONLY  = "MIG_READ_ONLY"
+	DEFERRABLE = "MIG_DEFERRABLE"
+
+	SCHEMA_FILE = "MIG_SCHEMA_FILE"
+	DUMP_SCHEMA = "MIG_DUMP_SCHEMA"
+
+	SCRATCH_CONNECTION = "MIG_SCRATCH_CONNECTION"
+
+	LOG_LEVEL  = "MIG_LOG_LEVEL"
+	LOG_FORMAT = "MIG_LOG_FORMAT"
+	LOG_FILE   = "MIG_LOG_FILE"
 )
 
 func GetConfigFromEnvVars() (MigConfig, error) {
@@ -12,9 +42,125 @@ func GetConfigFromEnvVars() (MigConfig, error) {
 	migrations := os.Getenv(MIGRATIONS)
 
 	config := MigConfig{
-		Connection: connection,
-		Migrations: migrations,
+		Connection:  connection,
+		Migrations:  migrations,
+		Environment: os.Getenv(ENV),
+		SchemaFile:  os.Getenv(SCHEMA_FILE),
+		Scratch:     os.Getenv(SCRATCH_CONNECTION),
+
+		Logging: logging.Settings{File: os.Getenv(LOG_FILE)},
+	}
+
+	protected, err := getEnvBool(PROTECTED)
+	if err != nil {
+		return config, err
+	}
+
+	config.Protected = protected
+
+	config.ConnectRetries, err = getEnvInt(CONNECT_RETRIES)
+	if err != nil {
+		return config, err
+	}
+
+	durations := map[string]*time.Duration{
+		CONNECT_TIMEOUT:   &config.ConnectTimeout,
+		TIMEOUT:           &config.Timeout,
+		LOCK_TIMEOUT:      &config.LockTimeout,
+		STATEMENT_TIMEOUT: &config.StatementTimeout,
+	}
+
+	for name, target := range durations {
+		*target, err = getEnvDuration(name)
+		if err != nil {
+			return config, err
+		}
+	}
+
+	if isolation := os.Getenv(ISOLATION); isolation != "" {
+		config.TxSettings.Isolation, err = database.ParseIsolation(isolation)
+		if err != nil {
+			return config, fmt.Errorf("%s: %w", ISOLATION, err)
+		}
+	}
+
+	config.TxSettings.ReadOnly, err = getEnvBool(READ_ONLY)
+	if err != nil {
+		return config, err
+	}
+
+	config.TxSettings.Deferrable, err = getEnvBool(DEFERRABLE)
+	if err != nil {
+		return config, err
+	}
+
+	config.DumpSchema, err = getEnvBool(DUMP_SCHEMA)
+	if err != nil {
+		return config, err
+	}
+
+	if level := os.Getenv(LOG_LEVEL); level != "" {
+		if _, err = logging.ParseLevel(level); err != nil {
+			return config, fmt.Errorf("%s: %w", LOG_LEVEL, err)
+		}
+
+		config.Logging.Level = level
+	}
+
+	if format := os.Getenv(LOG_FORMAT); format != "" {
+		config.Logging.Format, err = logging.ParseFormat(format)
+		if err != nil {
+			return config, fmt.Errorf("%s: %w", LOG_FORMAT, err)
+		}
 	}
 
 	return config, nil
 }
+
+// Reads a boolean environment variable. A missing or empty variable is false.
+func getEnvBool(name string) (bool, error) {
+	value := os.Getenv(name)
+
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean, got '%s'", name, value)
+	}
+
+	return parsed, nil
+}
+
+// Reads an integer environment variable. A missing or empty variable is zero.
+func getEnvInt(name string) (int, error) {
+	value := os.Getenv(name)
+
+	if value == "" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got '%s'", name, value)
+	}
+
+	return parsed, nil
+}
+
+// Reads a duration environment variable, e.g. 30s. A missing or empty variable is zero.
+func getEnvDuration(name string) (time.Duration, error) {
+	value := os.Getenv(name)
+
+	if value == "" {
+		return 0, nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("%s must be a duration such as 30s, got '%s'", name, value)
+	}
+
+	return parsed, nil
+}
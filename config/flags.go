@@ -1,9 +1,14 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/DavidGamba/go-getoptions"
+	"github.com/tlhunter/mig/database"
+	"github.com/tlhunter/mig/logging"
 )
 
 func GetConfigFromProcessFlags() (MigConfig, []string, error) {
@@ -12,6 +17,27 @@ func GetConfigFromProcessFlags() (MigConfig, []string, error) {
 	migrations := opt.String("migrations", "")
 	migRcPath := opt.String("file", "")
 	outputJson := opt.Bool("json", false)
+	protected := opt.Bool("protected", false)
+	confirmed := opt.Bool("i-know-what-im-doing", false)
+	environment := opt.String("env", "")
+	connectRetries := opt.String("connect-retries", "")
+	connectTimeout := opt.String("connect-timeout", "")
+	timeout := opt.String("timeout", "")
+	lockTimeout := opt.String("lock-timeout", "")
+	statementTimeout := opt.String("statement-timeout", "")
+	isolation := opt.String("isolation", "")
+	readOnly := opt.Bool("read-only", false)
+	deferrable := opt.Bool("deferrable", false)
+	singleTransaction := opt.Bool("single-transaction", false)
+	strict := opt.Bool("strict", false)
+	initialize := opt.Bool("init", false)
+	fix := opt.Bool("fix", false)
+	schemaFile := opt.String("schema-file", "")
+	dumpSchema := opt.Bool("dump-schema", false)
+	scratch := opt.String("scratch", "")
+	logLevel := opt.String("log-level", "")
+	logFormat := opt.String("log-format", "")
+	logFile := opt.String("log-file", "")
 
 	subcommand, err := opt.Parse(os.Args[1:])
 
@@ -20,11 +46,84 @@ func GetConfigFromProcessFlags() (MigConfig, []string, error) {
 		Migrations: *migrations,
 		MigRcPath:  *migRcPath,
 		OutputJson: *outputJson,
+		Protected:  *protected,
+		Confirmed:  *confirmed,
+
+		SingleTransaction: *singleTransaction,
+		Strict:            *strict,
+		Init:              *initialize,
+		Fix:               *fix,
+
+		SchemaFile: *schemaFile,
+		DumpSchema: *dumpSchema,
+		Scratch:    *scratch,
+
+		Environment: *environment,
+
+		Logging: logging.Settings{File: *logFile},
 	}
 
 	if err != nil {
 		return config, subcommand, err
 	}
 
+	// parsed here rather than by getoptions so that every invalid flag gets the same kind of message
+	if *connectRetries != "" {
+		retries, err := strconv.Atoi(*connectRetries)
+		if err != nil || retries < 0 {
+			return config, subcommand, fmt.Errorf("--connect-retries must be a positive integer, got '%s'", *connectRetries)
+		}
+
+		config.ConnectRetries = retries
+	}
+
+	if *isolation != "" {
+		config.TxSettings.Isolation, err = database.ParseIsolation(*isolation)
+		if err != nil {
+			return config, subcommand, fmt.Errorf("--%w", err)
+		}
+	}
+
+	if *logLevel != "" {
+		if _, err = logging.ParseLevel(*logLevel); err != nil {
+			return config, subcommand, fmt.Errorf("--%w", err)
+		}
+
+		config.Logging.Level = *logLevel
+	}
+
+	if *logFormat != "" {
+		config.Logging.Format, err = logging.ParseFormat(*logFormat)
+		if err != nil {
+			return config, subcommand, fmt.Errorf("--%w", err)
+		}
+	}
+
+	config.TxSettings.ReadOnly = *readOnly
+	config.TxSettings.Deferrable = *deferrable
+
+	durations := map[string]struct {
+		value  string
+		target *time.Duration
+	}{
+		"connect-timeout":   {*connectTimeout, &config.ConnectTimeout},
+		"timeout":           {*timeout, &config.Timeout},
+		"lock-timeout":      {*lockTimeout, &config.LockTimeout},
+		"statement-timeout": {*statementTimeout, &config.StatementTimeout},
+	}
+
+	for name, flag := range durations {
+		if flag.value == "" {
+			continue
+		}
+
+		parsed, err := time.ParseDuration(flag.value)
+		if err != nil || parsed < 0 {
+			return config, subcommand, fmt.Errorf("--%s must be a duration such as 30s, got '%s'", name, flag.value)
+		}
+
+		*flag.target = parsed
+	}
+
 	return config, subcommand, nil
 }
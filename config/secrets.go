@@ -0,0 +1,114 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/tlhunter/mig/database"
+)
+
+const (
+	CONNECTION_FILE  = "MIG_CONNECTION_FILE"
+	PASSWORD         = "MIG_PASSWORD"
+	PASSWORD_FILE    = "MIG_PASSWORD_FILE"
+	PASSWORD_COMMAND = "MIG_PASSWORD_COMMAND"
+)
+
+// Secrets are indirections which resolve to credentials.
+// They're only read from environment variables, which includes the .migrc file.
+type Secrets struct {
+	ConnectionFile  string // path to a file containing the connection string
+	Password        string // plain password
+	PasswordFile    string // path to a file containing the password
+	PasswordCommand string // command which prints the password to stdout
+}
+
+func GetSecretsFromEnvVars() Secrets {
+	return Secrets{
+		ConnectionFile:  os.Getenv(CONNECTION_FILE),
+		Password:        os.Getenv(PASSWORD),
+		PasswordFile:    os.Getenv(PASSWORD_FILE),
+		PasswordCommand: os.Getenv(PASSWORD_COMMAND),
+	}
+}
+
+// Reads the connection string from the connection file, if one was provided.
+func (s Secrets) ReadConnection() (string, error) {
+	if s.ConnectionFile == "" {
+		return "", nil
+	}
+
+	return readSecretFile(s.ConnectionFile)
+}
+
+// Determines the password from the first of the password indirections to be set.
+// An empty string is returned when no password indirection is used.
+func (s Secrets) ReadPassword() (string, error) {
+	if s.Password != "" {
+		return s.Password, nil
+	}
+
+	if s.PasswordFile != "" {
+		return readSecretFile(s.PasswordFile)
+	}
+
+	if s.PasswordCommand != "" {
+		return runSecretCommand(s.PasswordCommand)
+	}
+
+	return "", nil
+}
+
+// Places a password into the connection string, replacing any existing password.
+// The password doesn't need to be URL escaped.
+func MergePassword(connection string, password string) (string, error) {
+	u, err := url.Parse(connection)
+	if err != nil {
+		return connection, fmt.Errorf("unable to parse connection %s", database.RedactConnection(connection))
+	}
+
+	if u.User == nil || u.User.Username() == "" {
+		return connection, errors.New("a password was provided but the connection doesn't have a user")
+	}
+
+	u.User = url.UserPassword(u.User.Username(), password)
+
+	return u.String(), nil
+}
+
+// Secret files, like those mounted by Docker and Kubernetes, usually end with a newline
+func readSecretFile(path string) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(contents), "\r\n"), nil
+}
+
+func runSecretCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	var stdout bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s failed: %w", PASSWORD_COMMAND, err)
+	}
+
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
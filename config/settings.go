@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+
+	"github.com/tlhunter/mig/database"
+)
+
+const (
+	DRIVER   = "MIG_DRIVER"
+	HOST     = "MIG_HOST"
+	PORT     = "MIG_PORT"
+	USER     = "MIG_USER"
+	DATABASE = "MIG_DATABASE"
+	TLS      = "MIG_TLS"
+
+	TLS_CA          = "MIG_TLS_CA"
+	TLS_CERT        = "MIG_TLS_CERT"
+	TLS_KEY         = "MIG_TLS_KEY"
+	TLS_SERVER_NAME = "MIG_TLS_SERVER_NAME"
+)
+
+// The standard libpq environment variables
+const (
+	PG_HOST     = "PGHOST"
+	PG_PORT     = "PGPORT"
+	PG_USER     = "PGUSER"
+	PG_PASSWORD = "PGPASSWORD"
+	PG_DATABASE = "PGDATABASE"
+	PG_SSLMODE  = "PGSSLMODE"
+
+	PG_SSLROOTCERT = "PGSSLROOTCERT"
+	PG_SSLCERT     = "PGSSLCERT"
+	PG_SSLKEY      = "PGSSLKEY"
+)
+
+// Reads the discrete connection settings used when a connection string isn't provided.
+// The MIG_ variables take priority over the PG variables, which only apply to PostgreSQL.
+// The password is handled separately by Secrets.
+func GetSettingsFromEnvVars() database.Settings {
+	settings := database.Settings{
+		Driver:   os.Getenv(DRIVER),
+		Host:     os.Getenv(HOST),
+		Port:     os.Getenv(PORT),
+		User:     os.Getenv(USER),
+		Database: os.Getenv(DATABASE),
+		Tls:      os.Getenv(TLS),
+
+		TlsCa:         os.Getenv(TLS_CA),
+		TlsCert:       os.Getenv(TLS_CERT),
+		TlsKey:        os.Getenv(TLS_KEY),
+		TlsServerName: os.Getenv(TLS_SERVER_NAME),
+	}
+
+	pg := database.Settings{
+		Host:     os.Getenv(PG_HOST),
+		Port:     os.Getenv(PG_PORT),
+		User:     os.Getenv(PG_USER),
+		Password: os.Getenv(PG_PASSWORD),
+		Database: os.Getenv(PG_DATABASE),
+		Tls:      sslModeToTls(os.Getenv(PG_SSLMODE)),
+
+		TlsCa:   os.Getenv(PG_SSLROOTCERT),
+		TlsCert: os.Getenv(PG_SSLCERT),
+		TlsKey:  os.Getenv(PG_SSLKEY),
+	}
+
+	if settings.Driver == "" && !pg.IsEmpty() {
+		settings.Driver = "postgresql"
+	}
+
+	if settings.Driver != "postgresql" {
+		return settings
+	}
+
+	settings.Host = firstNonEmpty(settings.Host, pg.Host)
+	settings.Port = firstNonEmpty(settings.Port, pg.Port)
+	settings.User = firstNonEmpty(settings.User, pg.User)
+	settings.Password = pg.Password
+	settings.Database = firstNonEmpty(settings.Database, pg.Database)
+	settings.Tls = firstNonEmpty(settings.Tls, pg.Tls)
+	settings.TlsCa = firstNonEmpty(settings.TlsCa, pg.TlsCa)
+	settings.TlsCert = firstNonEmpty(settings.TlsCert, pg.TlsCert)
+	settings.TlsKey = firstNonEmpty(settings.TlsKey, pg.TlsKey)
+
+	return settings
+}
+
+// Maps libpq sslmode values to the mig tls values
+func sslModeToTls(sslMode string) string {
+	switch sslMode {
+	case "":
+		return ""
+	case "verify-full", "verify-ca":
+		return "verify"
+	case "disable":
+		return "disable"
+	default:
+		return "insecure" // allow, prefer, require
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A table, column, or index which differs between two schemas
+type SchemaDifference struct {
+	Code    string `json:"code"` // machine-keyable difference code
+	Table   string `json:"table"`
+	Name    string `json:"name,omitempty"` // the column or index
+	Message string `json:"message"`
+}
+
+// Reports the tables, columns, and indexes which are missing from the actual schema or unexpected in it.
+// Column types, nullability, and primary keys are only compared when both schemas come from the same
+// kind of database, since every database reports types differently.
+func CompareSchemas(expected Schema, actual Schema, compareTypes bool) []SchemaDifference {
+	var differences []SchemaDifference
+
+	for _, table := range expected.Tables {
+		other, ok := actual.Table(table.Name)
+		if !ok {
+			differences = append(differences, SchemaDifference{
+				Code:    "missing_table",
+				Table:   table.Name,
+				Message: fmt.Sprintf("the table %s is missing", table.Name),
+			})
+			continue
+		}
+
+		differences = append(differences, compareTables(table, other, compareTypes)...)
+	}
+
+	for _, table := range actual.Tables {
+		if _, ok := expected.Table(table.Name); !ok {
+			differences = append(differences, SchemaDifference{
+				Code:    "unexpected_table",
+				Table:   table.Name,
+				Message: fmt.Sprintf("the table %s is unexpected", table.Name),
+			})
+		}
+	}
+
+	return differences
+}
+
+func compareTables(expected Table, actual Table, compareTypes bool) []SchemaDifference {
+	var differences []SchemaDifference
+
+	for _, column := range expected.Columns {
+		other, ok := actual.Column(column.Name)
+		if !ok {
+			differences = append(differences, SchemaDifference{
+				Code:    "missing_column",
+				Table:   expected.Name,
+				Name:    column.Name,
+				Message: fmt.Sprintf("the column %s.%s is missing", expected.Name, column.Name),
+			})
+		} else if compareTypes && (other.Type != column.Type || other.Nullable != column.Nullable) {
+			differences = append(differences, SchemaDifference{
+				Code:    "changed_column",
+				Table:   expected.Name,
+				Name:    column.Name,
+				Message: fmt.Sprintf("expected %s.%s to be %s but found %s", expected.Name, column.Name, describeColumn(column), describeColumn(other)),
+			})
+		}
+	}
+
+	for _, column := range actual.Columns {
+		if _, ok := expected.Column(column.Name); !ok {
+			differences = append(differences, SchemaDifference{
+				Code:    "unexpected_column",
+				Table:   expected.Name,
+				Name:    column.Name,
+				Message: fmt.Sprintf("the column %s.%s is unexpected", expected.Name, column.Name),
+			})
+		}
+	}
+
+	if compareTypes && strings.Join(expected.PrimaryKey, ",") != strings.Join(actual.PrimaryKey, ",") {
+		differences = append(differences, SchemaDifference{
+			Code:    "changed_primary_key",
+			Table:   expected.Name,
+			Message: fmt.Sprintf("expected %s to have a primary key of (%s) but found (%s)", expected.Name, strings.Join(expected.PrimaryKey, ", "), strings.Join(actual.PrimaryKey, ", ")),
+		})
+	}
+
+	for _, index := range expected.Indexes {
+		if !contains(actual.Indexes, index) {
+			differences = append(differences, SchemaDifference{
+				Code:    "missing_index",
+				Table:   expected.Name,
+				Name:    index,
+				Message: fmt.Sprintf("the index %s on %s is missing", index, expected.Name),
+			})
+		}
+	}
+
+	for _, index := range actual.Indexes {
+		if !contains(expected.Indexes, index) {
+			differences = append(differences, SchemaDifference{
+				Code:    "unexpected_index",
+				Table:   expected.Name,
+				Name:    index,
+				Message: fmt.Sprintf("the index %s on %s is unexpected", index, expected.Name),
+			})
+		}
+	}
+
+	return differences
+}
+
+func describeColumn(column Column) string {
+	if column.Nullable {
+		return column.Type + " NULL"
+	}
+
+	return column.Type + " NOT NULL"
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
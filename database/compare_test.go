@@ -0,0 +1,45 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareSchemas(t *testing.T) {
+	expected := Schema{Tables: []Table{
+		{
+			Name:       "users",
+			Columns:    []Column{{Name: "id", Type: "integer"}, {Name: "email", Type: "varchar"}},
+			PrimaryKey: []string{"id"},
+			Indexes:    []string{"users_email"},
+		},
+		{Name: "posts", Columns: []Column{{Name: "id", Type: "integer"}}},
+	}}
+
+	actual := Schema{Tables: []Table{
+		{
+			Name:       "users",
+			Columns:    []Column{{Name: "id", Type: "integer"}, {Name: "email", Type: "text", Nullable: true}, {Name: "hotfix", Type: "integer"}},
+			PrimaryKey: []string{"id"},
+			Indexes:    []string{"users_hotfix"},
+		},
+		{Name: "audit", Columns: []Column{{Name: "id", Type: "integer"}}},
+	}}
+
+	var codes []string
+	for _, difference := range CompareSchemas(expected, actual, true) {
+		codes = append(codes, difference.Code)
+	}
+
+	assert.Equal(t, []string{"changed_column", "unexpected_column", "missing_index", "unexpected_index", "missing_table", "unexpected_table"}, codes)
+
+	codes = nil
+	for _, difference := range CompareSchemas(expected, actual, false) {
+		codes = append(codes, difference.Code)
+	}
+
+	assert.Equal(t, []string{"unexpected_column", "missing_index", "unexpected_index", "missing_table", "unexpected_table"}, codes, "types are ignored across databases")
+
+	assert.Empty(t, CompareSchemas(expected, expected, true))
+}
@@ -1,97 +1,243 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/url"
+	"regexp"
 	"strings"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/lib/pq"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type DbBox struct {
 	Db   *sql.DB
-	Type string // TODO: Make this lowercase
+	Tx   *sql.Tx         // queries run in this transaction when set, see Begin
+	Type string          // TODO: Make this lowercase
+	Ctx  context.Context // queries are cancelled along with this context, nil means never
 
 	IsPostgres bool // indicates this connection is for PostgreSQL
 	IsMysql    bool // indicates this connection is for MySQL
 	IsSqlite   bool // indicates this connection is for Sqlite
 }
 
+// The subset of methods shared by sql.DB and sql.Tx
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Returns a copy of the box whose queries are bound to the provided context
+func (dbox DbBox) WithContext(ctx context.Context) DbBox {
+	dbox.Ctx = ctx
+	return dbox
+}
+
+func (dbox DbBox) Context() context.Context {
+	if dbox.Ctx == nil {
+		return context.Background()
+	}
+
+	return dbox.Ctx
+}
+
+func (dbox DbBox) conn() queryer {
+	if dbox.Tx != nil {
+		return dbox.Tx
+	}
+
+	return dbox.Db
+}
+
+// Returns a copy of the box whose queries run in a new transaction.
+// The transaction is rolled back when the context of the box is cancelled.
+func (dbox DbBox) Begin(settings TxSettings) (DbBox, error) {
+	ctx := dbox.Context()
+
+	var txOpts *sql.TxOptions
+	if !settings.IsDefault() {
+		txOpts = &sql.TxOptions{Isolation: settings.Isolation, ReadOnly: settings.ReadOnly}
+	}
+
+	tx, err := dbox.Db.BeginTx(ctx, txOpts)
+	if err != nil {
+		return dbox, err
+	}
+
+	if settings.Deferrable {
+		// database/sql has no option for this, it must precede any other query in the transaction
+		_, err = tx.ExecContext(ctx, "SET TRANSACTION DEFERRABLE;")
+		if err != nil {
+			tx.Rollback()
+			return dbox, err
+		}
+	}
+
+	dbox.Tx = tx
+
+	slog.Debug("began transaction", "database", dbox.Type)
+
+	return dbox, nil
+}
+
+func (dbox DbBox) InTx() bool {
+	return dbox.Tx != nil
+}
+
+func (dbox DbBox) Commit() error {
+	err := dbox.Tx.Commit()
+	if err != nil {
+		slog.Error("unable to commit transaction", "database", dbox.Type, "error", err)
+	} else {
+		slog.Debug("committed transaction", "database", dbox.Type)
+	}
+
+	return err
+}
+
+// Rolling back a transaction which was already committed or rolled back does nothing
+func (dbox DbBox) Rollback() error {
+	err := dbox.Tx.Rollback()
+	if errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+
+	if err != nil {
+		slog.Error("unable to roll back transaction", "database", dbox.Type, "error", err)
+	} else {
+		slog.Debug("rolled back transaction", "database", dbox.Type)
+	}
+
+	return err
+}
+
 func (dbox DbBox) GetQuery(qb QueryBox) string {
 	return qb.For(dbox.Type)
 }
 
 func (dbox DbBox) Exec(qb QueryBox, args ...any) (sql.Result, error) {
-	return dbox.Db.Exec(qb.For(dbox.Type), args...)
+	start := time.Now()
+	result, err := dbox.conn().ExecContext(dbox.Context(), qb.For(dbox.Type), args...)
+	dbox.logStatement(qb.For(dbox.Type), start, err)
+
+	return result, err
 }
 
 func (dbox DbBox) Query(qb QueryBox, args ...any) (*sql.Rows, error) {
-	return dbox.Db.Query(qb.For(dbox.Type), args...)
+	start := time.Now()
+	rows, err := dbox.conn().QueryContext(dbox.Context(), qb.For(dbox.Type), args...)
+	dbox.logStatement(qb.For(dbox.Type), start, err)
+
+	return rows, err
 }
 
+// The error of the query is only known once the row is scanned so it isn't logged
 func (dbox DbBox) QueryRow(qb QueryBox, args ...any) *sql.Row {
-	return dbox.Db.QueryRow(qb.For(dbox.Type), args...)
+	start := time.Now()
+	row := dbox.conn().QueryRowContext(dbox.Context(), qb.For(dbox.Type), args...)
+	dbox.logStatement(qb.For(dbox.Type), start, nil)
+
+	return row
 }
 
-// This is a convenience wrapper around running up and down transaction queries
-func (dbox DbBox) ExecMaybeTx(query string, transaction bool) error {
-	if transaction {
-		tx, err := dbox.Db.Begin()
-		if err != nil {
-			return err
-		}
+// Statements are logged at the debug level since every query of mig and of the migrations is included
+func (dbox DbBox) logStatement(query string, start time.Time, err error) {
+	attrs := []any{"database", dbox.Type, "tx", dbox.InTx(), "elapsed", time.Since(start), "sql", query}
 
-		defer tx.Rollback()
+	if err != nil {
+		slog.Debug("statement failed", append(attrs, "error", err)...)
+	} else {
+		slog.Debug("executed statement", attrs...)
+	}
+}
 
-		_, err = tx.Exec(query)
-		if err != nil {
-			return err
-		}
+// This is a convenience wrapper around running up and down transaction queries.
+// When the context of the box is cancelled the running statement is cancelled and the transaction rolled back.
+// When the box is already bound to a transaction the query runs as part of it.
+func (dbox DbBox) ExecMaybeTx(query string, transaction bool, settings TxSettings) error {
+	if !transaction || dbox.InTx() {
+		start := time.Now()
+		_, err := dbox.conn().ExecContext(dbox.Context(), query)
+		dbox.logStatement(query, start, err)
 
-		return tx.Commit()
-	} else {
-		_, err := dbox.Db.Exec(query)
+		return err
+	}
 
+	txbox, err := dbox.Begin(settings)
+	if err != nil {
+		return err
+	}
+
+	defer txbox.Rollback()
+
+	start := time.Now()
+	_, err = txbox.conn().ExecContext(txbox.Context(), query)
+	txbox.logStatement(query, start, err)
+	if err != nil {
 		return err
 	}
 
+	return txbox.Commit()
 }
 
-// mig needs a common TLS flag mapping across all RDBMS
-// Postgres
-//   verify -> verify-full
-//   insecure -> require
-//   disable -> disable
-// MySQL
-//   verify -> true
-//   insecure -> skip-verify
-//   disable -> false
+type ConnectOptions struct {
+	Retries int           // additional attempts after the first failed attempt
+	Timeout time.Duration // overall time limit for connecting, zero for no limit
+
+	LockTimeout      time.Duration // session limit for waiting on a lock, zero for the database default
+	StatementTimeout time.Duration // session limit for a single statement, zero for the database default
+}
+
+const (
+	INITIAL_BACKOFF = 250 * time.Millisecond
+	MAX_BACKOFF     = 5 * time.Second
+)
+
+func Connect(connection string, opts ConnectOptions) (DbBox, error) {
+	start := time.Now()
+	redacted := RedactConnection(connection)
+
+	slog.Debug("connecting", "connection", redacted, "retries", opts.Retries, "timeout", opts.Timeout)
+
+	dbox, err := connect(connection, opts)
+	if err != nil {
+		slog.Error("unable to connect", "connection", redacted, "error", err)
+		return dbox, err
+	}
+
+	slog.Info("connected", "database", dbox.Type, "connection", redacted, "elapsed", time.Since(start))
+
+	return dbox, nil
+}
 
-func Connect(connection string) (DbBox, error) {
+func connect(connection string, opts ConnectOptions) (DbBox, error) {
 	var dbox DbBox
 	u, err := url.Parse(connection)
+	if err != nil {
+		return dbox, errors.New("unable to parse connection url!")
+	}
 
 	dbox.Type = u.Scheme
 
 	qs, err := url.ParseQuery(u.RawQuery)
-	tls_in := qs.Get("tls")
-
 	if err != nil {
 		return dbox, errors.New("unable to parse connection url!")
 	}
 
 	if u.Scheme == "postgresql" {
 		dbox.IsPostgres = true
-		tls := "disable"
 
-		if tls_in == "verify" {
-			tls = "verify-full"
-		} else if tls_in == "insecure" {
-			tls = "require"
+		tlsOpts, err := GetTlsOptions(qs)
+		if err != nil {
+			return dbox, err
 		}
 
 		port := "5432"
@@ -99,16 +245,47 @@ func Connect(connection string) (DbBox, error) {
 			port = u.Port()
 		}
 
-		dbox.Db, err = sql.Open("postgres", fmt.Sprintf("postgresql://%s@%s:%s%s?sslmode=%s", u.User, u.Hostname(), port, u.Path, tls))
+		host := u.Hostname()
+		if tlsOpts.ServerName != "" {
+			// the driver verifies against the host it's given, see addressOverrideDialer
+			host = tlsOpts.ServerName
+		}
 
+		params, err := GetDriverParams("postgresql", qs)
 		if err != nil {
-			return dbox, errors.New("unable to connect to postgresql database!")
+			return dbox, err
 		}
 
-		err = dbox.Db.Ping()
+		applySessionTimeouts("postgresql", params, opts)
+
+		query := tlsOpts.PostgresParams()
+		for key, value := range params {
+			query.Set(key, value)
+		}
+
+		dsn := url.URL{
+			Scheme:   "postgresql",
+			User:     u.User,
+			Host:     net.JoinHostPort(host, port),
+			Path:     u.Path,
+			RawQuery: query.Encode(),
+		}
 
+		connector, err := pq.NewConnector(dsn.String())
 		if err != nil {
-			return dbox, errors.New("unable to connect to postgresql database!")
+			return dbox, fmt.Errorf("unable to connect to postgresql database: %w", err)
+		}
+
+		if tlsOpts.ServerName != "" {
+			connector.Dialer(addressOverrideDialer{address: net.JoinHostPort(u.Hostname(), port)})
+		}
+
+		dbox.Db = sql.OpenDB(connector)
+
+		err = ping(dbox.Db, opts)
+
+		if err != nil {
+			return dbox, fmt.Errorf("unable to connect to postgresql database: %w", err)
 		}
 	} else if u.Scheme == "mysql" {
 		dbox.IsMysql = true
@@ -117,44 +294,71 @@ func Connect(connection string) (DbBox, error) {
 			port = u.Port()
 		}
 
-		tls := "false"
+		tlsOpts, err := GetTlsOptions(qs)
+		if err != nil {
+			return dbox, err
+		}
+
+		tls, err := tlsOpts.MysqlConfigName(u.Hostname())
+		if err != nil {
+			return dbox, err
+		}
 
-		if tls_in == "verify" {
-			tls = "true"
-		} else if tls_in == "insecure" {
-			tls = "skip-verify"
+		params, err := GetDriverParams("mysql", qs)
+		if err != nil {
+			return dbox, err
 		}
 
-		// multiStatements=true required to run multiple queries in a single call, basically all migrations
-		mysqlConnString := fmt.Sprintf("%s@tcp(%s:%s)%s?tls=%s&multiStatements=true&parseTime=true", u.User, u.Hostname(), port, u.Path, tls)
+		applySessionTimeouts("mysql", params, opts)
+
+		// the mysql DSN isn't a URL so credentials are passed along unescaped
+		mysqlConfig := mysql.NewConfig()
+		mysqlConfig.User = u.User.Username()
+		mysqlConfig.Passwd, _ = u.User.Password()
+		mysqlConfig.Net = "tcp"
+		mysqlConfig.Addr = net.JoinHostPort(u.Hostname(), port)
+		mysqlConfig.DBName = strings.TrimPrefix(u.Path, "/")
+		mysqlConfig.TLSConfig = tls
+		mysqlConfig.MultiStatements = true // required to run multiple queries in a single call, basically all migrations
+		mysqlConfig.ParseTime = true
+		mysqlConfig.Params = params // the driver parses known parameters out of these when opening
 
-		dbox.Db, err = sql.Open("mysql", mysqlConnString)
+		dbox.Db, err = sql.Open("mysql", mysqlConfig.FormatDSN())
 
 		if err != nil {
-			return dbox, errors.New("unable to connect to mysql database!")
+			return dbox, fmt.Errorf("unable to connect to mysql database: %w", err)
 		}
 
-		err := dbox.Db.Ping()
+		err = ping(dbox.Db, opts)
 
 		if err != nil {
-			return dbox, errors.New("unable to connect to mysql database!")
+			return dbox, fmt.Errorf("unable to connect to mysql database: %w", err)
 		}
-	} else if u.Scheme == "sqlite" { // or sqlite3?
+	} else if u.Scheme == "sqlite" || u.Scheme == "file" { // or sqlite3?
 		dbox.IsSqlite = true
+		dbox.Type = "sqlite"
 
-		// TODO: The connection format for sqlite sucks. Maybe use "sqlite:/path.db" instead?
+		path, err := SqlitePath(u)
+		if err != nil {
+			return dbox, err
+		}
 
-		hostname := u.Hostname()
-		if hostname != "localhost" && hostname != "127.0.0.1" && hostname != "::1" {
-			return dbox, errors.New("sqlite connection requires a host name of localhost!")
+		params, err := GetDriverParams("sqlite", qs)
+		if err != nil {
+			return dbox, err
 		}
 
-		path := strings.TrimPrefix(u.Path, "/") // /foo -> foo, //foo -> /foo
+		applySessionTimeouts("sqlite", params, opts)
 
-		dbox.Db, err = sql.Open("sqlite3", path)
+		dbox.Db, err = sql.Open("sqlite3", sqliteDsn(u.Scheme, path, params))
 
 		if err != nil {
-			return dbox, errors.New("unable to connect to sqlite database!")
+			return dbox, fmt.Errorf("unable to connect to sqlite database: %w", err)
+		}
+
+		if path == ":memory:" || params["mode"] == "memory" {
+			// every connection to an in-memory database gets its own empty database
+			dbox.Db.SetMaxOpenConns(1)
 		}
 	} else {
 		return dbox, errors.New(fmt.Sprintf("mig doesn't support the '%s' database", u.Scheme))
@@ -162,3 +366,119 @@ func Connect(connection string) (DbBox, error) {
 
 	return dbox, nil
 }
+
+// Assembles the name passed to the sqlite driver. The driver reads its own _ prefixed parameters from
+// any name but only honors the mode and cache URI parameters for file: names.
+func sqliteDsn(scheme string, path string, params map[string]string) string {
+	query := url.Values{}
+	for key, value := range params {
+		query.Set(SQLITE_PARAM_NAMES[key], value)
+	}
+
+	dsn := path
+	if scheme == "file" || params["mode"] != "" || params["cache"] != "" {
+		dsn = "file:" + path
+	}
+	if len(query) > 0 {
+		dsn += "?" + query.Encode()
+	}
+
+	return dsn
+}
+
+// Pings the database until it responds, backing off exponentially between attempts.
+// With a timeout but no retries it keeps trying until the timeout is reached.
+// The error from the last real attempt is returned rather than a timeout error.
+func ping(db *sql.DB, opts ConnectOptions) error {
+	ctx := context.Background()
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	delay := INITIAL_BACKOFF
+	var lastErr error
+
+	for attempt := 0; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() == nil || lastErr == nil {
+			lastErr = err
+		}
+
+		unlimited := opts.Retries == 0 && opts.Timeout > 0
+		if attempt >= opts.Retries && !unlimited {
+			return lastErr
+		}
+
+		slog.Warn("connection attempt failed", "attempt", attempt+1, "retry_in", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up after %d attempts: %w", attempt+1, lastErr)
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > MAX_BACKOFF {
+			delay = MAX_BACKOFF
+		}
+	}
+}
+
+// Returns the name of the database that a connection string refers to.
+// For SQLite this is the path to the database file.
+func GetDatabaseName(connection string) (string, error) {
+	u, err := url.Parse(connection)
+	if err != nil {
+		return "", errors.New("unable to parse connection url!")
+	}
+
+	if u.Scheme == "sqlite" || u.Scheme == "file" {
+		return SqlitePath(u)
+	}
+
+	return strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// Determines the path to a SQLite database from the supported connection formats:
+//
+//	sqlite:path.db, file:path.db        -> path.db
+//	sqlite:///abs/path.db, file:///abs  -> /abs/path.db
+//	sqlite::memory:                     -> :memory:
+//	sqlite://localhost/path.db          -> path.db (the original format)
+//	sqlite://localhost//abs/path.db     -> /abs/path.db
+func SqlitePath(u *url.URL) (string, error) {
+	if u.Opaque != "" {
+		return u.Opaque, nil
+	}
+
+	if u.Host == "" {
+		return u.Path, nil
+	}
+
+	hostname := u.Hostname()
+	if hostname != "localhost" && hostname != "127.0.0.1" && hostname != "::1" {
+		return "", errors.New("sqlite connection requires a host name of localhost!")
+	}
+
+	return strings.TrimPrefix(u.Path, "/"), nil // /foo -> foo, //foo -> /foo
+}
+
+var passwordPattern = regexp.MustCompile(`:[^:@/]*@`)
+
+// Replaces the password within a connection string so that it's safe to display.
+func RedactConnection(connection string) string {
+	u, err := url.Parse(connection)
+	if err != nil {
+		// the url can't be parsed, fall back to a naive replacement
+		return passwordPattern.ReplaceAllString(connection, ":xxxxx@")
+	}
+
+	return u.Redacted()
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSqlitePath(t *testing.T) {
+	cases := map[string]string{
+		"sqlite:test.db":                  "test.db",
+		"sqlite:///tmp/test.db":           "/tmp/test.db",
+		"sqlite::memory:":                 ":memory:",
+		"file:test.db?mode=ro":            "test.db",
+		"file:///tmp/test.db":             "/tmp/test.db",
+		"sqlite://localhost/test.db":      "test.db",
+		"sqlite://localhost//tmp/test.db": "/tmp/test.db",
+		"sqlite://localhost/../test.db":   "../test.db",
+		"sqlite:test.db?journal_mode=WAL": "test.db",
+		"sqlite://127.0.0.1/data/test.db": "data/test.db",
+	}
+
+	for connection, expected := range cases {
+		u, err := url.Parse(connection)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path, err := SqlitePath(u)
+		assert.NoError(t, err, connection)
+		assert.Equal(t, expected, path, connection)
+	}
+
+	u, _ := url.Parse("sqlite://example.com/test.db")
+	_, err := SqlitePath(u)
+	assert.Error(t, err, "sqlite requires localhost when a host is provided")
+}
+
+func TestSqliteDsn(t *testing.T) {
+	assert.Equal(t, "test.db", sqliteDsn("sqlite", "test.db", map[string]string{}))
+	assert.Equal(t, "test.db?_journal_mode=WAL", sqliteDsn("sqlite", "test.db", map[string]string{"journal_mode": "WAL"}))
+	assert.Equal(t, "file:test.db", sqliteDsn("file", "test.db", map[string]string{}))
+	assert.Equal(t, "file:test.db?mode=memory", sqliteDsn("sqlite", "test.db", map[string]string{"mode": "memory"}), "mode requires a file: name")
+	assert.Equal(t, "file:test.db?cache=shared", sqliteDsn("sqlite", "test.db", map[string]string{"cache": "shared"}), "cache requires a file: name")
+}
+
+func TestSqliteMemoryMode(t *testing.T) {
+	dir := t.TempDir()
+
+	dbox, err := Connect("sqlite:"+dir+"/test.db?mode=memory", ConnectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dbox.Db.Exec("CREATE TABLE users (id int);")
+	dbox.Db.Close()
+	assert.NoError(t, err)
+
+	assert.NoFileExists(t, dir+"/test.db", "an in-memory database doesn't create the file")
+}
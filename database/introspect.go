@@ -0,0 +1,198 @@
+package database
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	TABLE_EXISTS = QueryBox{
+		Postgres: `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1;`,
+		Mysql:    `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?;`,
+		Sqlite:   `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`,
+	}
+	LIST_TABLES = QueryBox{
+		Postgres: `SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' ORDER BY table_name;`,
+		Mysql:    `SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE' ORDER BY table_name;`,
+		Sqlite:   `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name;`,
+	}
+	// indexes created by a primary key, unique, or exclusion constraint are left out, except by mysql
+	LIST_INDEXES = QueryBox{
+		Postgres: `SELECT c.relname FROM pg_index i
+			JOIN pg_class c ON c.oid = i.indexrelid
+			JOIN pg_class t ON t.oid = i.indrelid
+			JOIN pg_namespace n ON n.oid = t.relnamespace
+			WHERE n.nspname = current_schema() AND t.relname = $1 AND NOT EXISTS (
+				SELECT 1 FROM pg_constraint WHERE conrelid = i.indrelid AND conindid = i.indexrelid AND contype IN ('p', 'u', 'x')
+			)
+			ORDER BY c.relname;`,
+		Mysql: `SELECT DISTINCT index_name FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = ? AND index_name <> 'PRIMARY'
+			ORDER BY index_name;`,
+		Sqlite: `SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND name NOT LIKE 'sqlite_%' ORDER BY name;`,
+	}
+	DESCRIBE_COLUMNS = QueryBox{
+		Postgres: `SELECT column_name, data_type, is_nullable = 'YES'
+			FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1
+			ORDER BY ordinal_position;`,
+		Mysql: `SELECT column_name, data_type, is_nullable = 'YES'
+			FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ?
+			ORDER BY ordinal_position;`,
+		Sqlite: `SELECT name, type, "notnull" = 0 FROM pragma_table_info(?) ORDER BY cid;`,
+	}
+	DESCRIBE_PRIMARY_KEY = QueryBox{
+		Postgres: `SELECT kcu.column_name
+			FROM information_schema.table_constraints tc
+			JOIN information_schema.key_column_usage kcu
+				ON kcu.constraint_name = tc.constraint_name AND kcu.table_schema = tc.table_schema AND kcu.table_name = tc.table_name
+			WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = current_schema() AND tc.table_name = $1
+			ORDER BY kcu.ordinal_position;`,
+		Mysql: `SELECT column_name
+			FROM information_schema.key_column_usage
+			WHERE constraint_name = 'PRIMARY' AND table_schema = DATABASE() AND table_name = ?
+			ORDER BY ordinal_position;`,
+		Sqlite: `SELECT name FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk;`,
+	}
+)
+
+// sqlite reports the declared type, e.g. varchar(255), which is reduced to varchar
+var typeLength = regexp.MustCompile(`\s*\(.*\)$`)
+
+type Column struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // lowercase type as reported by the database, without a length
+	Nullable bool   `json:"nullable"`
+}
+
+type Table struct {
+	Name       string   `json:"name"`
+	Columns    []Column `json:"columns"`
+	PrimaryKey []string `json:"primary_key"`
+	Indexes    []string `json:"indexes,omitempty"` // only populated by DescribeSchema
+}
+
+// The tables of a schema, sorted by name
+type Schema struct {
+	Tables []Table `json:"tables"`
+}
+
+func (s Schema) Table(name string) (Table, bool) {
+	for _, table := range s.Tables {
+		if table.Name == name {
+			return table, true
+		}
+	}
+
+	return Table{}, false
+}
+
+func (t Table) Column(name string) (Column, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+
+	return Column{}, false
+}
+
+// Whether a table exists in the current schema, or database for mysql
+func (dbox DbBox) TableExists(name string) (bool, error) {
+	var count int
+
+	err := dbox.QueryRow(TABLE_EXISTS, name).Scan(&count)
+
+	return count > 0, err
+}
+
+// Lists the tables of the current schema, or database for mysql
+func (dbox DbBox) ListTables() ([]string, error) {
+	return queryStrings(dbox, LIST_TABLES)
+}
+
+// Describes every table of the current schema, or database for mysql, along with its indexes
+func (dbox DbBox) DescribeSchema(exclude []string) (Schema, error) {
+	var schema Schema
+
+	excluded := map[string]bool{}
+	for _, table := range exclude {
+		excluded[table] = true
+	}
+
+	names, err := dbox.ListTables()
+	if err != nil {
+		return schema, err
+	}
+
+	for _, name := range names {
+		if excluded[name] {
+			continue
+		}
+
+		table, _, err := dbox.DescribeTable(name)
+		if err != nil {
+			return schema, err
+		}
+
+		if table.Indexes, err = queryStrings(dbox, LIST_INDEXES, name); err != nil {
+			return schema, err
+		}
+
+		schema.Tables = append(schema.Tables, table)
+	}
+
+	return schema, nil
+}
+
+// Describes the columns and primary key of a table in the current schema, or database for mysql.
+// The returned bool is false when the table doesn't exist.
+func (dbox DbBox) DescribeTable(name string) (Table, bool, error) {
+	table := Table{Name: name}
+
+	rows, err := dbox.Query(DESCRIBE_COLUMNS, name)
+	if err != nil {
+		return table, false, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var column Column
+
+		if err = rows.Scan(&column.Name, &column.Type, &column.Nullable); err != nil {
+			return table, false, err
+		}
+
+		column.Type = typeLength.ReplaceAllString(strings.ToLower(column.Type), "")
+		table.Columns = append(table.Columns, column)
+	}
+
+	if err = rows.Err(); err != nil {
+		return table, false, err
+	}
+
+	if len(table.Columns) == 0 {
+		return table, false, nil
+	}
+
+	pkRows, err := dbox.Query(DESCRIBE_PRIMARY_KEY, name)
+	if err != nil {
+		return table, true, err
+	}
+
+	defer pkRows.Close()
+
+	for pkRows.Next() {
+		var column string
+
+		if err = pkRows.Scan(&column); err != nil {
+			return table, true, err
+		}
+
+		table.PrimaryKey = append(table.PrimaryKey, column)
+	}
+
+	return table, true, pkRows.Err()
+}
@@ -1,15 +1,30 @@
 package database
 
+import "log/slog"
+
 func ObtainLock(dbox DbBox) (bool, error) {
+	var locked bool
+	var err error
+
 	if dbox.IsPostgres {
-		return postgresObtainLock(dbox)
+		locked, err = postgresObtainLock(dbox)
 	} else if dbox.IsMysql {
-		return mysqlObtainLock(dbox)
+		locked, err = mysqlObtainLock(dbox)
 	} else if dbox.IsSqlite {
-		return sqliteObtainLock(dbox)
+		locked, err = sqliteObtainLock(dbox)
 	} else {
 		panic("unknown database: " + dbox.Type)
 	}
+
+	if err != nil {
+		slog.Error("unable to obtain the lock", "error", err)
+	} else if locked {
+		slog.Info("obtained the lock")
+	} else {
+		slog.Warn("the lock is already held")
+	}
+
+	return locked, err
 }
 
 func postgresObtainLock(dbox DbBox) (bool, error) {
@@ -55,15 +70,28 @@ func sqliteObtainLock(dbox DbBox) (bool, error) {
 }
 
 func ReleaseLock(dbox DbBox) (bool, error) {
+	var released bool
+	var err error
+
 	if dbox.IsPostgres {
-		return postgresReleaseLock(dbox)
+		released, err = postgresReleaseLock(dbox)
 	} else if dbox.IsMysql {
-		return mysqlReleaseLock(dbox)
+		released, err = mysqlReleaseLock(dbox)
 	} else if dbox.IsSqlite {
-		return sqliteReleaseLock(dbox)
+		released, err = sqliteReleaseLock(dbox)
 	} else {
 		panic("unknown database: " + dbox.Type)
 	}
+
+	if err != nil {
+		slog.Error("unable to release the lock", "error", err)
+	} else if released {
+		slog.Info("released the lock")
+	} else {
+		slog.Warn("the lock was already released")
+	}
+
+	return released, err
 }
 
 func postgresReleaseLock(dbox DbBox) (bool, error) {
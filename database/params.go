@@ -0,0 +1,163 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type paramValidator func(value string) error
+
+// Options consumed by mig itself. These are never forwarded to a driver.
+var MIG_PARAMS = map[string]bool{
+	"tls":             true,
+	"tls_ca":          true,
+	"tls_cert":        true,
+	"tls_key":         true,
+	"tls_server_name": true,
+}
+
+// Driver parameters which mig sets itself and which can't be overridden
+var RESERVED_PARAMS = map[string][]string{
+	"postgresql": {"host", "port", "user", "password", "dbname", "sslmode", "sslrootcert", "sslcert", "sslkey", "sslinline"},
+	"mysql":      {"tls", "multiStatements", "parseTime", "allowAllFiles", "interpolateParams"},
+}
+
+// Driver parameters which are forwarded to the driver after being validated
+var ALLOWED_PARAMS = map[string]map[string]paramValidator{
+	"postgresql": {
+		"application_name":          anyValue,
+		"fallback_application_name": anyValue,
+		"search_path":               anyValue,
+		"connect_timeout":           isInteger,
+		"options":                   anyValue,
+		"client_encoding":           anyValue,
+		"datestyle":                 anyValue,
+		"timezone":                  anyValue,
+		"statement_timeout":         isInteger,
+		"lock_timeout":              isInteger,
+	},
+	"mysql": {
+		"charset":                 anyValue,
+		"collation":               anyValue,
+		"loc":                     isLocation,
+		"timeout":                 isDuration,
+		"readTimeout":             isDuration,
+		"writeTimeout":            isDuration,
+		"maxAllowedPacket":        isInteger,
+		"allowNativePasswords":    isBool,
+		"allowCleartextPasswords": isBool,
+		"serverPubKey":            anyValue,
+		"time_zone":               anyValue,
+		"sql_mode":                anyValue,
+	},
+	"sqlite": {
+		"journal_mode": isJournalMode,
+		"busy_timeout": isInteger,
+		"foreign_keys": isOnOff,
+		"mode":         anyValue,
+		"cache":        anyValue,
+	},
+}
+
+// The sqlite driver prefixes the parameters it handles itself with an underscore
+var SQLITE_PARAM_NAMES = map[string]string{
+	"journal_mode": "_journal_mode",
+	"busy_timeout": "_busy_timeout",
+	"foreign_keys": "_foreign_keys",
+	"mode":         "mode",
+	"cache":        "cache",
+}
+
+// Returns the connection string query parameters which should be forwarded to the driver.
+// Parameters used by mig are skipped and all others must be on the allow list.
+func GetDriverParams(driver string, qs url.Values) (map[string]string, error) {
+	params := map[string]string{}
+
+	for key, values := range qs {
+		if MIG_PARAMS[key] {
+			continue
+		}
+
+		for _, reserved := range RESERVED_PARAMS[driver] {
+			if key == reserved {
+				return params, fmt.Errorf("the '%s' parameter is reserved by mig", key)
+			}
+		}
+
+		validate, ok := ALLOWED_PARAMS[driver][key]
+		if !ok {
+			return params, fmt.Errorf("the '%s' parameter isn't supported for %s, supported parameters: %s", key, driver, strings.Join(AllowedParams(driver), ", "))
+		}
+
+		if len(values) != 1 {
+			return params, fmt.Errorf("the '%s' parameter was provided more than once", key)
+		}
+
+		if err := validate(values[0]); err != nil {
+			return params, fmt.Errorf("invalid value for the '%s' parameter: %w", key, err)
+		}
+
+		params[key] = values[0]
+	}
+
+	return params, nil
+}
+
+// Lists the parameters which may be forwarded to the driver, sorted by name
+func AllowedParams(driver string) []string {
+	var names []string
+
+	for name := range ALLOWED_PARAMS[driver] {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func anyValue(value string) error {
+	return nil
+}
+
+func isInteger(value string) error {
+	_, err := strconv.Atoi(value)
+	return err
+}
+
+func isBool(value string) error {
+	_, err := strconv.ParseBool(value)
+	return err
+}
+
+func isDuration(value string) error {
+	_, err := time.ParseDuration(value)
+	return err
+}
+
+func isLocation(value string) error {
+	_, err := time.LoadLocation(value)
+	return err
+}
+
+func isJournalMode(value string) error {
+	switch strings.ToUpper(value) {
+	case "DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF":
+		return nil
+	}
+
+	return fmt.Errorf("unknown journal mode '%s'", value)
+}
+
+func isOnOff(value string) error {
+	switch strings.ToLower(value) {
+	case "on", "off", "true", "false", "yes", "no", "1", "0":
+		return nil
+	}
+
+	return fmt.Errorf("expected on or off, got '%s'", value)
+}
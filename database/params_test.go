@@ -0,0 +1,32 @@
+package database
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDriverParams(t *testing.T) {
+	qs, _ := url.ParseQuery("tls=verify&search_path=app,public&application_name=mig&connect_timeout=5")
+
+	params, err := GetDriverParams("postgresql", qs)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"search_path":      "app,public",
+		"application_name": "mig",
+		"connect_timeout":  "5",
+	}, params, "mig options are skipped and driver options are forwarded")
+
+	qs, _ = url.ParseQuery("sslmode=disable")
+	_, err = GetDriverParams("postgresql", qs)
+	assert.ErrorContains(t, err, "reserved", "reserved parameters are refused")
+
+	qs, _ = url.ParseQuery("charset=utf8mb4&loc=Nowhere/Special")
+	_, err = GetDriverParams("mysql", qs)
+	assert.ErrorContains(t, err, "loc", "values are validated")
+
+	qs, _ = url.ParseQuery("made_up=1")
+	_, err = GetDriverParams("mysql", qs)
+	assert.ErrorContains(t, err, "isn't supported", "unknown parameters are refused")
+}
@@ -0,0 +1,398 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	SQLITE_SCHEMA = QueryBox{
+		Sqlite: `SELECT tbl_name, sql FROM sqlite_master
+			WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+			ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 WHEN 'view' THEN 2 ELSE 3 END, name;`,
+	}
+	MYSQL_TABLES = QueryBox{
+		Mysql: `SELECT table_name, table_type = 'VIEW' FROM information_schema.tables
+			WHERE table_schema = DATABASE()
+			ORDER BY table_type = 'VIEW', table_name;`,
+	}
+	POSTGRES_TABLES = QueryBox{
+		Postgres: `SELECT c.oid, c.relname, quote_ident(c.relname), c.relkind = 'v' FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p', 'v')
+			ORDER BY c.relkind = 'v', c.relname;`,
+	}
+	POSTGRES_COLUMNS = QueryBox{
+		Postgres: `SELECT quote_ident(a.attname), format_type(a.atttypid, a.atttypmod), a.attnotnull,
+				COALESCE(pg_get_expr(d.adbin, d.adrelid), ''), a.attidentity
+			FROM pg_attribute a
+			LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+			WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+			ORDER BY a.attnum;`,
+	}
+	POSTGRES_CONSTRAINTS = QueryBox{
+		Postgres: `SELECT quote_ident(conname), pg_get_constraintdef(oid), contype = 'f' FROM pg_constraint
+			WHERE conrelid = $1 AND contype IN ('p', 'u', 'c', 'x', 'f')
+			ORDER BY CASE contype WHEN 'p' THEN 0 WHEN 'u' THEN 1 WHEN 'c' THEN 2 ELSE 3 END, conname;`,
+	}
+	// indexes created by a primary key, unique, or exclusion constraint are part of the constraint
+	POSTGRES_INDEXES = QueryBox{
+		Postgres: `SELECT pg_get_indexdef(i.indexrelid) FROM pg_index i
+			JOIN pg_class c ON c.oid = i.indexrelid
+			WHERE i.indrelid = $1 AND NOT EXISTS (
+				SELECT 1 FROM pg_constraint WHERE conrelid = i.indrelid AND conindid = i.indexrelid AND contype IN ('p', 'u', 'x')
+			)
+			ORDER BY c.relname;`,
+	}
+	POSTGRES_VIEW = QueryBox{
+		Postgres: `SELECT pg_get_viewdef($1, true);`,
+	}
+	LIST_VIEWS = QueryBox{
+		Postgres: `SELECT table_name FROM information_schema.views WHERE table_schema = current_schema() ORDER BY table_name;`,
+		Mysql:    `SELECT table_name FROM information_schema.views WHERE table_schema = DATABASE() ORDER BY table_name;`,
+		Sqlite:   `SELECT name FROM sqlite_master WHERE type = 'view' ORDER BY name;`,
+	}
+)
+
+// mysql includes the next value of the auto increment counter and the user who created a view
+var mysqlAutoIncrement = regexp.MustCompile(` AUTO_INCREMENT=\d+`)
+var mysqlViewOptions = regexp.MustCompile(`ALGORITHM=\w+ DEFINER=\S+ SQL SECURITY \w+ `)
+
+// a column default of nextval() is the sequence of a serial column
+var postgresSerial = regexp.MustCompile(`^nextval\('[^']+'::regclass\)$`)
+
+// Returns the statements which create the tables, indexes, and views of the current schema, or database
+// for mysql, in an order they can be executed in. The excluded tables are skipped along with their indexes.
+// Only what can be introspected with queries is included, e.g. postgres functions and types are not.
+func (dbox DbBox) DumpSchema(exclude []string) ([]string, error) {
+	excluded := map[string]bool{}
+	for _, table := range exclude {
+		excluded[table] = true
+	}
+
+	if dbox.IsPostgres {
+		return postgresDumpSchema(dbox, excluded)
+	} else if dbox.IsMysql {
+		return mysqlDumpSchema(dbox, excluded)
+	} else if dbox.IsSqlite {
+		return sqliteDumpSchema(dbox, excluded)
+	}
+
+	panic("unknown database: " + dbox.Type)
+}
+
+// Drops every view and table of the current schema, or database for mysql, including the tracking tables.
+// It's only meant for disposable databases, e.g. the scratch database of mig diff.
+func (dbox DbBox) ClearSchema() error {
+	views, err := queryStrings(dbox, LIST_VIEWS)
+	if err != nil {
+		return err
+	}
+
+	tables, err := dbox.ListTables()
+	if err != nil {
+		return err
+	}
+
+	var statements []string
+
+	for _, view := range views {
+		statements = append(statements, "DROP VIEW "+dbox.quoteIdent(view)+";")
+	}
+
+	for _, table := range tables {
+		if dbox.IsPostgres {
+			statements = append(statements, "DROP TABLE "+dbox.quoteIdent(table)+" CASCADE;")
+		} else {
+			statements = append(statements, "DROP TABLE "+dbox.quoteIdent(table)+";")
+		}
+	}
+
+	if len(statements) == 0 {
+		return nil
+	}
+
+	// the statements run as one script so that mysql disables the foreign key checks on the same connection
+	script := strings.Join(statements, "\n")
+
+	_, err = dbox.Exec(QueryBox{
+		Postgres: script,
+		Mysql:    "SET FOREIGN_KEY_CHECKS = 0;\n" + script + "\nSET FOREIGN_KEY_CHECKS = 1;",
+		Sqlite:   script, // foreign keys aren't enforced by default
+	})
+
+	return err
+}
+
+func (dbox DbBox) quoteIdent(name string) string {
+	if dbox.IsMysql {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqlite keeps the statement which created each table, index, view, and trigger
+func sqliteDumpSchema(dbox DbBox, excluded map[string]bool) ([]string, error) {
+	var statements []string
+
+	rows, err := dbox.Query(SQLITE_SCHEMA)
+	if err != nil {
+		return statements, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, statement string
+
+		if err = rows.Scan(&table, &statement); err != nil {
+			return statements, err
+		}
+
+		if !excluded[table] {
+			statements = append(statements, statement+";")
+		}
+	}
+
+	return statements, rows.Err()
+}
+
+func mysqlDumpSchema(dbox DbBox, excluded map[string]bool) ([]string, error) {
+	type object struct {
+		name   string
+		isView bool
+	}
+
+	var objects []object
+
+	rows, err := dbox.Query(MYSQL_TABLES)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var o object
+
+		if err = rows.Scan(&o.name, &o.isView); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		if !excluded[o.name] {
+			objects = append(objects, o)
+		}
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// the tables are created in alphabetical order so foreign keys can reference a later table
+	statements := []string{"SET FOREIGN_KEY_CHECKS = 0;"}
+
+	for _, o := range objects {
+		quoted := "`" + strings.ReplaceAll(o.name, "`", "``") + "`"
+
+		var name, statement string
+
+		if o.isView {
+			var charset, collation string
+			err = dbox.QueryRow(QueryBox{Mysql: "SHOW CREATE VIEW " + quoted + ";"}).Scan(&name, &statement, &charset, &collation)
+			statement = mysqlViewOptions.ReplaceAllString(statement, "")
+		} else {
+			err = dbox.QueryRow(QueryBox{Mysql: "SHOW CREATE TABLE " + quoted + ";"}).Scan(&name, &statement)
+			statement = mysqlAutoIncrement.ReplaceAllString(statement, "")
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to describe %s: %w", o.name, err)
+		}
+
+		statements = append(statements, statement+";")
+	}
+
+	return append(statements, "SET FOREIGN_KEY_CHECKS = 1;"), nil
+}
+
+func postgresDumpSchema(dbox DbBox, excluded map[string]bool) ([]string, error) {
+	type object struct {
+		oid    int64
+		name   string
+		quoted string
+		isView bool
+	}
+
+	var objects []object
+
+	rows, err := dbox.Query(POSTGRES_TABLES)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var o object
+
+		if err = rows.Scan(&o.oid, &o.name, &o.quoted, &o.isView); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		if !excluded[o.name] {
+			objects = append(objects, o)
+		}
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var tables, indexes, views, foreignKeys []string
+
+	for _, o := range objects {
+		if o.isView {
+			var definition string
+			if err = dbox.QueryRow(POSTGRES_VIEW, o.oid).Scan(&definition); err != nil {
+				return nil, fmt.Errorf("unable to describe %s: %w", o.name, err)
+			}
+
+			views = append(views, "CREATE VIEW "+o.quoted+" AS\n"+strings.TrimSuffix(strings.TrimSpace(definition), ";")+";")
+			continue
+		}
+
+		table, keys, err := postgresCreateTable(dbox, o.oid, o.quoted)
+		if err != nil {
+			return nil, fmt.Errorf("unable to describe %s: %w", o.name, err)
+		}
+
+		tables = append(tables, table)
+		foreignKeys = append(foreignKeys, keys...)
+
+		tableIndexes, err := queryStrings(dbox, POSTGRES_INDEXES, o.oid)
+		if err != nil {
+			return nil, fmt.Errorf("unable to describe the indexes of %s: %w", o.name, err)
+		}
+
+		for _, index := range tableIndexes {
+			indexes = append(indexes, index+";")
+		}
+	}
+
+	// foreign keys are added once every table exists since they can reference a later table
+	statements := append(tables, indexes...)
+	statements = append(statements, foreignKeys...)
+
+	return append(statements, views...), nil
+}
+
+// Returns the create table statement along with statements adding its foreign keys
+func postgresCreateTable(dbox DbBox, oid int64, quoted string) (string, []string, error) {
+	var lines, foreignKeys []string
+
+	rows, err := dbox.Query(POSTGRES_COLUMNS, oid)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for rows.Next() {
+		var name, dataType, defaultValue, identity string
+		var notNull bool
+
+		if err = rows.Scan(&name, &dataType, &notNull, &defaultValue, &identity); err != nil {
+			rows.Close()
+			return "", nil, err
+		}
+
+		if postgresSerial.MatchString(defaultValue) {
+			switch dataType {
+			case "integer":
+				dataType, defaultValue = "serial", ""
+			case "bigint":
+				dataType, defaultValue = "bigserial", ""
+			case "smallint":
+				dataType, defaultValue = "smallserial", ""
+			}
+		}
+
+		line := name + " " + dataType
+
+		switch identity {
+		case "a":
+			line += " GENERATED ALWAYS AS IDENTITY"
+		case "d":
+			line += " GENERATED BY DEFAULT AS IDENTITY"
+		}
+
+		if notNull {
+			line += " NOT NULL"
+		}
+
+		if defaultValue != "" {
+			line += " DEFAULT " + defaultValue
+		}
+
+		lines = append(lines, line)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return "", nil, err
+	}
+
+	rows, err = dbox.Query(POSTGRES_CONSTRAINTS, oid)
+	if err != nil {
+		return "", nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, definition string
+		var foreign bool
+
+		if err = rows.Scan(&name, &definition, &foreign); err != nil {
+			return "", nil, err
+		}
+
+		if foreign {
+			foreignKeys = append(foreignKeys, "ALTER TABLE "+quoted+" ADD CONSTRAINT "+name+" "+definition+";")
+		} else {
+			lines = append(lines, "CONSTRAINT "+name+" "+definition)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return "", nil, err
+	}
+
+	return "CREATE TABLE " + quoted + " (\n    " + strings.Join(lines, ",\n    ") + "\n);", foreignKeys, nil
+}
+
+func queryStrings(dbox DbBox, qb QueryBox, args ...any) ([]string, error) {
+	var values []string
+
+	rows, err := dbox.Query(qb, args...)
+	if err != nil {
+		return values, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var value string
+
+		if err = rows.Scan(&value); err != nil {
+			return values, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
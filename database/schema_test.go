@@ -0,0 +1,39 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSqliteDumpSchema(t *testing.T) {
+	dbox, err := Connect("sqlite::memory:", ConnectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer dbox.Db.Close()
+
+	_, err = dbox.Db.Exec(`CREATE TABLE users (id int, email varchar(255));
+	CREATE INDEX users_email ON users (email);
+	CREATE TABLE migrations (id int);
+	CREATE INDEX migrations_id ON migrations (id);
+	CREATE VIEW emails AS SELECT email FROM users;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements, err := dbox.DumpSchema([]string{"migrations"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE TABLE users (id int, email varchar(255));",
+		"CREATE INDEX users_email ON users (email);",
+		"CREATE VIEW emails AS SELECT email FROM users;",
+	}, statements, "excluded tables are skipped along with their indexes")
+
+	assert.NoError(t, dbox.ClearSchema())
+
+	statements, err = dbox.DumpSchema(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, statements)
+}
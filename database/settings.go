@@ -0,0 +1,93 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Settings are the discrete pieces of a connection which are assembled into a connection string.
+// Values don't need to be URL escaped.
+type Settings struct {
+	Driver   string // postgresql, mysql, sqlite
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Database string // database name, or the path to the database file for sqlite
+	Tls      string // verify, insecure, disable
+
+	TlsCa         string // path to a PEM CA bundle
+	TlsCert       string // path to a PEM client certificate
+	TlsKey        string // path to a PEM client key
+	TlsServerName string // name to verify the server certificate against
+}
+
+func (s Settings) IsEmpty() bool {
+	return s == Settings{}
+}
+
+// Assembles the settings into a connection string which can be passed to Connect.
+func (s Settings) Url() (string, error) {
+	if s.Driver == "" {
+		return "", errors.New("a driver is required when using discrete connection settings")
+	}
+
+	u := url.URL{
+		Scheme: s.Driver,
+	}
+
+	switch s.Driver {
+	case "postgresql", "mysql":
+		host := s.Host
+		if host == "" {
+			host = "localhost"
+		}
+
+		if s.Port != "" {
+			u.Host = net.JoinHostPort(host, s.Port)
+		} else {
+			u.Host = host
+		}
+
+		if s.User != "" && s.Password != "" {
+			u.User = url.UserPassword(s.User, s.Password)
+		} else if s.User != "" {
+			u.User = url.User(s.User)
+		}
+
+		u.Path = "/" + s.Database
+
+	case "sqlite":
+		if s.Database == "" {
+			return "", errors.New("sqlite requires a database path")
+		}
+
+		if strings.HasPrefix(s.Database, "/") {
+			u.Path = s.Database // sqlite:///abs/path.db
+		} else {
+			u.Opaque = s.Database // sqlite:path.db
+		}
+
+	default:
+		return "", fmt.Errorf("mig doesn't support the '%s' database", s.Driver)
+	}
+
+	qs := url.Values{}
+	setIfPresent(qs, "tls", s.Tls)
+	setIfPresent(qs, "tls_ca", s.TlsCa)
+	setIfPresent(qs, "tls_cert", s.TlsCert)
+	setIfPresent(qs, "tls_key", s.TlsKey)
+	setIfPresent(qs, "tls_server_name", s.TlsServerName)
+	u.RawQuery = qs.Encode()
+
+	return u.String(), nil
+}
+
+func setIfPresent(qs url.Values, key string, value string) {
+	if value != "" {
+		qs.Set(key, value)
+	}
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// mig maps the lock and statement timeouts to a session setting for each RDBMS
+// Postgres
+//   lock      -> lock_timeout (ms)
+//   statement -> statement_timeout (ms)
+// MySQL
+//   lock      -> lock_wait_timeout and innodb_lock_wait_timeout (s)
+//   statement -> max_execution_time (ms), which MySQL only applies to SELECT
+// SQLite
+//   lock      -> busy_timeout (ms)
+//   statement -> unsupported, a migration timeout still interrupts it
+
+// Adds the session settings for the timeouts to the driver parameters.
+// Values provided explicitly in the connection string take priority.
+func applySessionTimeouts(driver string, params map[string]string, opts ConnectOptions) {
+	set := func(key string, value string) {
+		if _, ok := params[key]; !ok {
+			params[key] = value
+		}
+	}
+
+	if opts.LockTimeout > 0 {
+		switch driver {
+		case "postgresql":
+			set("lock_timeout", millis(opts.LockTimeout))
+		case "mysql":
+			set("lock_wait_timeout", seconds(opts.LockTimeout))
+			set("innodb_lock_wait_timeout", seconds(opts.LockTimeout))
+		case "sqlite":
+			set("busy_timeout", millis(opts.LockTimeout))
+		}
+	}
+
+	if opts.StatementTimeout > 0 {
+		switch driver {
+		case "postgresql":
+			set("statement_timeout", millis(opts.StatementTimeout))
+		case "mysql":
+			set("max_execution_time", millis(opts.StatementTimeout))
+		}
+	}
+}
+
+func millis(d time.Duration) string {
+	return strconv.FormatInt(d.Milliseconds(), 10)
+}
+
+// MySQL lock timeouts are whole seconds with a minimum of one
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(d.Seconds()))))
+}
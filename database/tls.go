@@ -0,0 +1,179 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Name of the TLS config registered with the MySQL driver
+const MYSQL_TLS_CONFIG = "mig"
+
+// TlsOptions are the TLS related connection string options.
+type TlsOptions struct {
+	Mode       string // verify, insecure, disable
+	Ca         string // path to a PEM CA bundle used to verify the server
+	Cert       string // path to a PEM client certificate
+	Key        string // path to a PEM client key
+	ServerName string // name to verify the server certificate against, instead of the host
+}
+
+func GetTlsOptions(qs url.Values) (TlsOptions, error) {
+	opts := TlsOptions{
+		Mode:       qs.Get("tls"),
+		Ca:         qs.Get("tls_ca"),
+		Cert:       qs.Get("tls_cert"),
+		Key:        qs.Get("tls_key"),
+		ServerName: qs.Get("tls_server_name"),
+	}
+
+	if opts.Mode != "" && opts.Mode != "verify" && opts.Mode != "insecure" && opts.Mode != "disable" {
+		return opts, fmt.Errorf("unsupported tls mode '%s'", opts.Mode)
+	}
+
+	if (opts.Cert == "") != (opts.Key == "") {
+		return opts, errors.New("tls_cert and tls_key must be provided together")
+	}
+
+	if opts.IsCustom() {
+		if opts.Mode == "disable" {
+			return opts, errors.New("tls options can't be used with tls=disable")
+		}
+
+		if opts.Mode == "" {
+			// asking for a CA or client cert only makes sense with TLS enabled
+			opts.Mode = "verify"
+		}
+	}
+
+	if opts.Mode == "" {
+		opts.Mode = "disable"
+	}
+
+	return opts, nil
+}
+
+// Whether any options beyond the tls mode were provided
+func (opts TlsOptions) IsCustom() bool {
+	return opts.Ca != "" || opts.Cert != "" || opts.ServerName != ""
+}
+
+// Builds a TLS config for drivers which accept one directly
+func (opts TlsOptions) Config(host string) (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: opts.Mode == "insecure",
+	}
+
+	if opts.ServerName != "" {
+		conf.ServerName = opts.ServerName
+	}
+
+	if opts.Ca != "" {
+		pem, err := os.ReadFile(opts.Ca)
+		if err != nil {
+			return nil, err
+		}
+
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("unable to parse a certificate from %s", opts.Ca)
+		}
+	}
+
+	if opts.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(opts.Cert, opts.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
+
+// mig needs a common TLS flag mapping across all RDBMS
+// Postgres
+//   verify -> verify-full
+//   insecure -> require
+//   disable -> disable
+// MySQL
+//   verify -> true
+//   insecure -> skip-verify
+//   disable -> false
+
+func (opts TlsOptions) PostgresParams() url.Values {
+	params := url.Values{}
+
+	switch opts.Mode {
+	case "verify":
+		params.Set("sslmode", "verify-full")
+	case "insecure":
+		params.Set("sslmode", "require")
+	default:
+		params.Set("sslmode", "disable")
+	}
+
+	if opts.Ca != "" {
+		params.Set("sslrootcert", opts.Ca)
+	}
+
+	if opts.Cert != "" {
+		params.Set("sslcert", opts.Cert)
+		params.Set("sslkey", opts.Key)
+	}
+
+	return params
+}
+
+// Returns the name of a TLS config for the MySQL driver, registering a custom one if needed
+func (opts TlsOptions) MysqlConfigName(host string) (string, error) {
+	if opts.IsCustom() {
+		conf, err := opts.Config(host)
+		if err != nil {
+			return "", err
+		}
+
+		return MYSQL_TLS_CONFIG, mysql.RegisterTLSConfig(MYSQL_TLS_CONFIG, conf)
+	}
+
+	switch opts.Mode {
+	case "verify":
+		return "true", nil
+	case "insecure":
+		return "skip-verify", nil
+	default:
+		return "false", nil
+	}
+}
+
+// The postgres driver verifies the certificate against the host it connects to.
+// To verify against a different server name the driver is told to connect to that name
+// while this dialer connects to the real address.
+type addressOverrideDialer struct {
+	address string
+	dialer  net.Dialer
+}
+
+func (d addressOverrideDialer) Dial(network, _ string) (net.Conn, error) {
+	return d.dialer.Dial(network, d.address)
+}
+
+func (d addressOverrideDialer) DialTimeout(network, _ string, timeout time.Duration) (net.Conn, error) {
+	dialer := d.dialer
+	dialer.Timeout = timeout
+	return dialer.Dial(network, d.address)
+}
+
+func (d addressOverrideDialer) DialContext(ctx context.Context, network, _ string) (net.Conn, error) {
+	return d.dialer.DialContext(ctx, network, d.address)
+}
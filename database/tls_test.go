@@ -0,0 +1,165 @@
+package database
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPem []byte
+	keyPem  []byte
+}
+
+// Generates a certificate signed by parent, or a self signed CA when parent is nil
+func generateCert(t *testing.T, name string, parent *testCert) testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer := template
+	signerKey := key
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer = parent.cert
+		signerKey = parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testCert{
+		cert:    cert,
+		key:     key,
+		certPem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPem:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+func writeFile(t *testing.T, dir string, name string, contents []byte) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTlsConfigWithLocalCa(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := generateCert(t, "mig test ca", nil)
+	server := generateCert(t, "db.internal", &ca)
+	client := generateCert(t, "mig", &ca)
+
+	caPath := writeFile(t, dir, "ca.pem", ca.certPem)
+	certPath := writeFile(t, dir, "client.pem", client.certPem)
+	keyPath := writeFile(t, dir, "client.key", client.keyPem)
+
+	serverCert, err := tls.X509KeyPair(server.certPem, server.keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCas := x509.NewCertPool()
+	clientCas.AddCert(ca.cert)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    clientCas,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	handshake := func(opts TlsOptions) error {
+		conf, err := opts.Config("127.0.0.1")
+		if err != nil {
+			return err
+		}
+
+		conn, err := tls.Dial("tcp", listener.Addr().String(), conf)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+
+	err = handshake(TlsOptions{Mode: "verify", Ca: caPath, Cert: certPath, Key: keyPath, ServerName: "db.internal"})
+	assert.NoError(t, err, "handshake with ca, client cert, and server name")
+
+	err = handshake(TlsOptions{Mode: "verify", Ca: caPath, Cert: certPath, Key: keyPath})
+	assert.Error(t, err, "server name doesn't match the host")
+
+	err = handshake(TlsOptions{Mode: "verify", Cert: certPath, Key: keyPath, ServerName: "db.internal"})
+	assert.Error(t, err, "server certificate isn't trusted without the ca")
+}
+
+func TestGetTlsOptions(t *testing.T) {
+	opts, err := GetTlsOptions(url.Values{"tls_ca": []string{"/ca.pem"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "verify", opts.Mode, "custom options imply verify")
+
+	_, err = GetTlsOptions(url.Values{"tls": []string{"disable"}, "tls_ca": []string{"/ca.pem"}})
+	assert.Error(t, err, "custom options conflict with disable")
+
+	_, err = GetTlsOptions(url.Values{"tls_cert": []string{"/cert.pem"}})
+	assert.Error(t, err, "cert requires key")
+
+	params := TlsOptions{Mode: "insecure", Ca: "/ca.pem"}.PostgresParams()
+	assert.Equal(t, "sslmode=require&sslrootcert=%2Fca.pem", params.Encode(), "postgres params")
+}
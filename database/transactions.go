@@ -0,0 +1,65 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TxSettings control the transaction a migration is wrapped in
+type TxSettings struct {
+	Isolation  sql.IsolationLevel // sql.LevelDefault uses the database default
+	ReadOnly   bool
+	Deferrable bool // PostgreSQL only, requires serializable and read only
+}
+
+var ISOLATION_LEVELS = map[string]sql.IsolationLevel{
+	"default":          sql.LevelDefault,
+	"read-uncommitted": sql.LevelReadUncommitted,
+	"read-committed":   sql.LevelReadCommitted,
+	"repeatable-read":  sql.LevelRepeatableRead,
+	"serializable":     sql.LevelSerializable,
+}
+
+// Accepts names such as serializable, read-committed, or "read committed"
+func ParseIsolation(value string) (sql.IsolationLevel, error) {
+	level, ok := ISOLATION_LEVELS[strings.ReplaceAll(strings.ToLower(strings.TrimSpace(value)), " ", "-")]
+	if !ok {
+		return sql.LevelDefault, fmt.Errorf("isolation must be one of read-uncommitted, read-committed, repeatable-read, serializable, got '%s'", value)
+	}
+
+	return level, nil
+}
+
+func (s TxSettings) IsDefault() bool {
+	return s == TxSettings{}
+}
+
+// Ensures that the database supports the transaction settings
+func (dbox DbBox) ValidateTx(s TxSettings) error {
+	if dbox.IsPostgres {
+		if s.Deferrable && (s.Isolation != sql.LevelSerializable || !s.ReadOnly) {
+			return errors.New("deferrable transactions must also be serializable and read only")
+		}
+	} else if dbox.IsMysql {
+		if s.Deferrable {
+			return errors.New("mysql doesn't support deferrable transactions")
+		}
+	} else if dbox.IsSqlite {
+		// sqlite transactions are always serializable and the driver ignores the options
+		if s.Isolation != sql.LevelDefault && s.Isolation != sql.LevelSerializable {
+			return errors.New("sqlite only supports serializable transactions")
+		}
+		if s.ReadOnly {
+			return errors.New("sqlite doesn't support read only transactions")
+		}
+		if s.Deferrable {
+			return errors.New("sqlite doesn't support deferrable transactions")
+		}
+	} else {
+		panic("unknown database: " + dbox.Type)
+	}
+
+	return nil
+}
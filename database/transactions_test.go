@@ -0,0 +1,34 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIsolation(t *testing.T) {
+	level, err := ParseIsolation("Read Committed")
+	assert.NoError(t, err)
+	assert.Equal(t, sql.LevelReadCommitted, level, "spaces and case are accepted")
+
+	_, err = ParseIsolation("snapshot")
+	assert.ErrorContains(t, err, "snapshot", "unknown levels are refused")
+}
+
+func TestValidateTx(t *testing.T) {
+	postgres := DbBox{Type: "postgresql", IsPostgres: true}
+	mysql := DbBox{Type: "mysql", IsMysql: true}
+	sqlite := DbBox{Type: "sqlite", IsSqlite: true}
+
+	deferrable := TxSettings{Isolation: sql.LevelSerializable, ReadOnly: true, Deferrable: true}
+	assert.NoError(t, postgres.ValidateTx(deferrable))
+	assert.Error(t, postgres.ValidateTx(TxSettings{Deferrable: true}), "deferrable requires serializable read only")
+	assert.Error(t, mysql.ValidateTx(deferrable), "mysql has no deferrable transactions")
+
+	assert.NoError(t, mysql.ValidateTx(TxSettings{Isolation: sql.LevelRepeatableRead, ReadOnly: true}))
+
+	assert.NoError(t, sqlite.ValidateTx(TxSettings{Isolation: sql.LevelSerializable}))
+	assert.Error(t, sqlite.ValidateTx(TxSettings{Isolation: sql.LevelReadCommitted}), "sqlite is always serializable")
+	assert.Error(t, sqlite.ValidateTx(TxSettings{ReadOnly: true}), "the sqlite driver ignores read only")
+}
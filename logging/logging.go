@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const DEF_FILE_LEVEL = "info" // level used when only a log file is provided
+
+var LEVELS = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// Settings control the logs, which are kept apart from the output of commands so stdout remains valid JSON
+type Settings struct {
+	Level  string // debug, info, warn, error, or empty to disable logging
+	Format string // text or json, defaults to text
+	File   string // logs are appended to this file instead of stderr
+}
+
+func ParseLevel(value string) (slog.Level, error) {
+	level, ok := LEVELS[strings.ToLower(strings.TrimSpace(value))]
+	if !ok {
+		return slog.LevelInfo, fmt.Errorf("log-level must be one of debug, info, warn, error, got '%s'", value)
+	}
+
+	return level, nil
+}
+
+func ParseFormat(value string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(value))
+	if format != "text" && format != "json" {
+		return "", fmt.Errorf("log-format must be one of text, json, got '%s'", value)
+	}
+
+	return format, nil
+}
+
+// Replaces the default logger used throughout mig. Logging is disabled unless a level or a file is provided.
+// The returned function closes the log file.
+func Configure(settings Settings) (func() error, error) {
+	closer := func() error { return nil }
+
+	if settings.Level == "" && settings.File == "" {
+		slog.SetDefault(slog.New(discardHandler{}))
+		return closer, nil
+	}
+
+	levelName := settings.Level
+	if levelName == "" {
+		levelName = DEF_FILE_LEVEL
+	}
+
+	level, err := ParseLevel(levelName)
+	if err != nil {
+		return closer, err
+	}
+
+	var out io.Writer = os.Stderr
+
+	if settings.File != "" {
+		file, err := os.OpenFile(settings.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return closer, err
+		}
+
+		out, closer = file, file.Close
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if settings.Format == "json" {
+		handler = slog.NewJSONHandler(out, opts)
+	} else {
+		handler = slog.NewTextHandler(out, opts)
+	}
+
+	slog.SetDefault(slog.New(handler))
+
+	return closer, nil
+}
+
+// slog.DiscardHandler requires a newer version of go
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
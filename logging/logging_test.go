@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel(" DEBUG ")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	_, err = ParseLevel("loud")
+	assert.EqualError(t, err, "log-level must be one of debug, info, warn, error, got 'loud'")
+
+	_, err = ParseFormat("xml")
+	assert.Error(t, err)
+}
+
+func TestConfigure(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	filename := t.TempDir() + "/mig.log"
+
+	closeLog, err := Configure(Settings{Format: "json", File: filename})
+	assert.NoError(t, err)
+
+	slog.Debug("hidden")
+	slog.Info("connected", "database", "sqlite")
+	assert.NoError(t, closeLog())
+
+	contents, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	assert.Len(t, lines, 1, "a log file without a level logs at info")
+	assert.Contains(t, lines[0], `"msg":"connected","database":"sqlite"`)
+
+	_, err = Configure(Settings{})
+	assert.NoError(t, err)
+	assert.False(t, slog.Default().Enabled(context.Background(), slog.LevelError), "logging is disabled by default")
+}
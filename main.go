@@ -5,24 +5,34 @@ import (
 
 	"github.com/tlhunter/mig/commands"
 	"github.com/tlhunter/mig/config"
+	"github.com/tlhunter/mig/logging"
 	"github.com/tlhunter/mig/result"
 )
 
 func main() {
 	cfg, subcommands, bail := config.GetConfig()
 
+	// logs go to stderr, or a file, so that stdout stays valid JSON
+	closeLog, err := logging.Configure(cfg.Logging)
+
 	var res result.Response
 
-	if bail != nil && len(subcommands) == 0 {
-		res.SetError("usage: mig <command>", "command_usage")
+	if err != nil {
+		res = *result.NewErrorWithDetails("unable to open the log file", "bad_config", err)
 	} else if bail != nil && len(subcommands) == 1 && subcommands[0] == "version" {
 		res = commands.CommandVersion(cfg)
+	} else if bail != nil && bail.ErrorCode == "command_usage" {
+		// flags which can't be parsed are reported even without a command
+		res = *bail
+	} else if len(subcommands) == 0 {
+		res.SetError("usage: mig <command>", "command_usage")
 	} else if bail != nil {
 		res = *bail
-	} else if bail == nil {
+	} else {
 		res = commands.Dispatch(cfg, subcommands)
 	}
 
 	res.Display(cfg.OutputJson)
+	closeLog()
 	os.Exit(int(res.ExitStatus))
 }
@@ -0,0 +1,197 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tlhunter/mig/database"
+)
+
+const DIRECTIVE_PREFIX = "--mig:"
+
+// Directives are optional per-migration settings placed in the header of a migration file, e.g.
+// --mig:timeout=5m
+type Directives struct {
+	Timeout   time.Duration      // overrides the global migration timeout
+	Envs      []string           // only run in these environments
+	Requires  []string           // migrations that must be applied first
+	Tags      []string           // free form labels
+	Replaces  []string           // migrations combined into this one by mig squash, oldest first
+	Isolation sql.IsolationLevel // transaction isolation level
+
+	ReadOnly   *bool // nil means the global setting is used
+	Deferrable *bool // nil means the global setting is used
+}
+
+var timestampPrefix = regexp.MustCompile(`^[0-9]+$`)
+
+func parseDirective(line string, directives *Directives, seen map[string]bool) error {
+	key, value, found := strings.Cut(strings.TrimPrefix(line, DIRECTIVE_PREFIX), "=")
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+
+	if !found || value == "" {
+		return fmt.Errorf("directive '%s' requires a value", line)
+	}
+
+	if seen[key] {
+		return fmt.Errorf("directive '%s' was provided more than once", key)
+	}
+	seen[key] = true
+
+	switch key {
+	case "timeout":
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("directive 'timeout' must be a duration such as 5m, got '%s'", value)
+		}
+		directives.Timeout = timeout
+
+	case "env":
+		directives.Envs = splitList(value)
+
+	case "requires":
+		directives.Requires = splitList(value)
+
+	case "tags":
+		directives.Tags = splitList(value)
+
+	case "replaces":
+		directives.Replaces = splitList(value)
+
+	case "isolation":
+		level, err := database.ParseIsolation(value)
+		if err != nil {
+			return fmt.Errorf("directive %w", err)
+		}
+		directives.Isolation = level
+
+	case "read-only":
+		readOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("directive 'read-only' must be true or false, got '%s'", value)
+		}
+		directives.ReadOnly = &readOnly
+
+	case "deferrable":
+		deferrable, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("directive 'deferrable' must be true or false, got '%s'", value)
+		}
+		directives.Deferrable = &deferrable
+
+	default:
+		return fmt.Errorf("unknown directive '%s'", key)
+	}
+
+	return nil
+}
+
+func splitList(value string) []string {
+	var list []string
+
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+
+	return list
+}
+
+// Whether the migration should be executed in the provided environment.
+// Migrations without an env directive run everywhere.
+func (d Directives) RunsIn(env string) (bool, error) {
+	if len(d.Envs) == 0 {
+		return true, nil
+	}
+
+	if env == "" {
+		return false, fmt.Errorf("the migration is restricted to the %s environment(s) but no environment is configured", strings.Join(d.Envs, ","))
+	}
+
+	for _, candidate := range d.Envs {
+		if candidate == env {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Returns the required migrations that haven't been applied.
+// A requirement matches the full filename, the filename without .sql, or a timestamp prefix.
+func (d Directives) MissingRequirements(history []MigrationRowStatus) []string {
+	var missing []string
+
+	for _, required := range d.Requires {
+		found := false
+
+		for _, entry := range history {
+			if entry.Status != "applied" && entry.Status != "missing" {
+				continue
+			}
+
+			if satisfies(entry.Migration.Name, required) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, required)
+		}
+	}
+
+	return missing
+}
+
+func satisfies(name string, required string) bool {
+	return name == required || strings.TrimSuffix(name, ".sql") == required ||
+		(timestampPrefix.MatchString(required) && strings.HasPrefix(name, required+"_"))
+}
+
+// Returns the replaced migrations that have been applied individually. When a squashed migration
+// hasn't been applied these were applied by a database which fell behind before the squash.
+func (d Directives) AppliedReplacements(history []MigrationRowStatus) []string {
+	var applied []string
+
+	for _, replaced := range d.Replaces {
+		for _, entry := range history {
+			if (entry.Status == "applied" || entry.Status == "missing") && entry.Migration.Name == replaced {
+				applied = append(applied, replaced)
+				break
+			}
+		}
+	}
+
+	return applied
+}
+
+// Applies the transaction directives on top of the global transaction settings
+func (d Directives) TxSettings(global database.TxSettings) database.TxSettings {
+	settings := global
+
+	if d.Isolation != sql.LevelDefault {
+		settings.Isolation = d.Isolation
+	}
+
+	if d.ReadOnly != nil {
+		settings.ReadOnly = *d.ReadOnly
+	}
+
+	if d.Deferrable != nil {
+		settings.Deferrable = *d.Deferrable
+	}
+
+	return settings
+}
+
+// Whether any of the directives relate to transactions
+func (d Directives) HasTxSettings() bool {
+	return d.Isolation != sql.LevelDefault || d.ReadOnly != nil || d.Deferrable != nil
+}
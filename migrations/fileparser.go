@@ -3,7 +3,9 @@ package migrations
 import (
 	"bufio"
 	"errors"
+	"log/slog"
 	"os"
+	"strings"
 )
 
 type MigrationPair struct {
@@ -11,6 +13,9 @@ type MigrationPair struct {
 	Down   string
 	UpTx   bool
 	DownTx bool
+
+	Directives Directives // settings from the --mig: lines in the file header
+	Checksum   string     // sha256 of the whole file, see FileChecksum
 }
 
 const (
@@ -30,10 +35,22 @@ const (
 
 // Opens a migration file then steps through it looking for an up and down block.
 // Queries within the two blocks are then returned.
-// Lines that fall outside of the blocks are ignored.
+// Lines that fall outside of the blocks are ignored, except for directives before the up block.
 // If it doesn't find a well formed up them down block an error is returned.
 // This is because any poorly-formed comments should not be mis-interpreted.
 func GetQueriesFromFile(filename string) (MigrationPair, error) {
+	pair, err := parseFile(filename)
+	if err != nil {
+		slog.Warn("unable to parse migration", "file", filename, "error", err)
+		return pair, err
+	}
+
+	slog.Debug("parsed migration", "file", filename, "up_tx", pair.UpTx, "down_tx", pair.DownTx, "checksum", pair.Checksum)
+
+	return pair, nil
+}
+
+func parseFile(filename string) (MigrationPair, error) {
 	pair := MigrationPair{
 		Up:     "",
 		Down:   "",
@@ -42,6 +59,7 @@ func GetQueriesFromFile(filename string) (MigrationPair, error) {
 	}
 
 	state := STATE_START
+	seenDirectives := map[string]bool{}
 
 	file, err := os.Open(filename)
 	if err != nil {
@@ -52,6 +70,9 @@ func GetQueriesFromFile(filename string) (MigrationPair, error) {
 
 	scanner.Split(bufio.ScanLines)
 
+	// the replaces directive of a squashed migration can exceed the default limit of 64KB per line
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 16*1024*1024)
+
 	for scanner.Scan() {
 		line := scanner.Text()
 		switch line {
@@ -96,7 +117,11 @@ func GetQueriesFromFile(filename string) (MigrationPair, error) {
 			state = STATE_FINISH
 
 		default:
-			if state == STATE_UP {
+			if state == STATE_START && strings.HasPrefix(line, DIRECTIVE_PREFIX) {
+				if err := parseDirective(line, &pair.Directives, seenDirectives); err != nil {
+					return pair, err
+				}
+			} else if state == STATE_UP {
 				pair.Up += line + "\n"
 			} else if state == STATE_DOWN {
 				pair.Down += line + "\n"
@@ -109,5 +134,11 @@ func GetQueriesFromFile(filename string) (MigrationPair, error) {
 		return pair, errors.New("failed to parse migration file")
 	}
 
-	return pair, nil
+	if pair.Directives.HasTxSettings() && !pair.UpTx && !pair.DownTx {
+		return pair, errors.New("the isolation, read-only, and deferrable directives require a transaction")
+	}
+
+	pair.Checksum, err = FileChecksum(filename)
+
+	return pair, err
 }
@@ -1,8 +1,12 @@
 package migrations
 
 import (
+	"database/sql"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -23,3 +27,50 @@ INSERT INTO users (username) VALUES ('tlhunter');`
 
 	assert.Equal(t, strings.Trim(pair.Up, " \n"), expectation, "queries aren't equal")
 }
+
+func TestGetQueriesFromFileDirectives(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "20230102000000_backfill.sql")
+	os.WriteFile(filename, []byte(`--mig:timeout=5m
+--mig:env=staging, prod
+--mig:requires=20230101120058
+--mig:tags=data
+--mig:isolation=serializable
+--BEGIN MIGRATION UP--
+UPDATE users SET username = lower(username);
+--END MIGRATION UP--
+--BEGIN MIGRATION DOWN--
+--END MIGRATION DOWN--`), 0644)
+
+	pair, err := GetQueriesFromFile(filename)
+	if err != nil {
+		t.Log("had an error", err)
+		t.Fail()
+		return
+	}
+
+	assert.Equal(t, Directives{
+		Timeout:   5 * time.Minute,
+		Envs:      []string{"staging", "prod"},
+		Requires:  []string{"20230101120058"},
+		Tags:      []string{"data"},
+		Isolation: sql.LevelSerializable,
+	}, pair.Directives, "directives aren't equal")
+
+	runs, _ := pair.Directives.RunsIn("dev")
+	assert.False(t, runs, "shouldn't run in other environments")
+
+	missing := pair.Directives.MissingRequirements([]MigrationRowStatus{{
+		Migration: MigrationRow{Name: "20230101120058_add_users_table.sql"},
+		Status:    "applied",
+	}})
+	assert.Empty(t, missing, "requirement is satisfied by the timestamp prefix")
+
+	os.WriteFile(filename, []byte(`--mig:retries=3
+--BEGIN MIGRATION UP--
+--END MIGRATION UP--
+--BEGIN MIGRATION DOWN--
+--END MIGRATION DOWN--`), 0644)
+
+	_, err = GetQueriesFromFile(filename)
+	assert.ErrorContains(t, err, "unknown directive", "unknown directives are refused")
+}
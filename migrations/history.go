@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tlhunter/mig/database"
+)
+
+var (
+	LIST_HISTORY = database.QueryBox{
+		Postgres: `SELECT name, direction, batch, migration_time, duration_ms, os_user, hostname, mig_version FROM migrations_history ORDER BY id ASC;`,
+		Mysql:    `SELECT name, direction, batch, migration_time, duration_ms, os_user, hostname, mig_version FROM migrations_history ORDER BY id ASC;`,
+		Sqlite:   `SELECT name, direction, batch, migration_time, duration_ms, os_user, hostname, mig_version FROM migrations_history ORDER BY id ASC;`,
+	}
+)
+
+// A single up or down run of a migration from the migrations_history table
+type MigrationRun struct {
+	Direction  string     `json:"direction"` // up or down
+	Batch      int        `json:"batch"`
+	Time       *time.Time `json:"time"`
+	DurationMs int64      `json:"duration_ms"`
+	OsUser     string     `json:"os_user,omitempty"`
+	Hostname   string     `json:"hostname,omitempty"`
+	MigVersion string     `json:"mig_version,omitempty"`
+}
+
+// Returns the runs of every migration keyed by migration name, oldest first.
+// Tables created by older versions of mig have no history so nothing is returned for them.
+func ListRuns(dbox database.DbBox) (map[string][]MigrationRun, error) {
+	runs := map[string][]MigrationRun{}
+
+	if !historyExists(dbox) {
+		return runs, nil
+	}
+
+	rows, err := dbox.Query(LIST_HISTORY)
+	if err != nil {
+		return runs, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var run MigrationRun
+		var runTime time.Time
+		var durationMs sql.NullInt64
+		var osUser, hostname, migVersion sql.NullString
+
+		err = rows.Scan(&name, &run.Direction, &run.Batch, &runTime, &durationMs, &osUser, &hostname, &migVersion)
+		if err != nil {
+			return runs, err
+		}
+
+		run.Time = &runTime
+		run.DurationMs = durationMs.Int64
+		run.OsUser = osUser.String
+		run.Hostname = hostname.String
+		run.MigVersion = migVersion.String
+
+		runs[name] = append(runs[name], run)
+	}
+
+	return runs, nil
+}
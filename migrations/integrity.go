@@ -0,0 +1,269 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tlhunter/mig/database"
+)
+
+var (
+	LOCK_ROWS = database.QueryBox{
+		Postgres: `SELECT "index", is_locked FROM migrations_lock;`,
+		Mysql:    "SELECT `index`, is_locked FROM migrations_lock;",
+		Sqlite:   `SELECT "index", is_locked FROM migrations_lock;`,
+	}
+)
+
+// A column of a tracking table along with the type each database reports for it
+type columnSpec struct {
+	Name     string
+	Postgres string
+	Mysql    string
+	Sqlite   string
+	Since    int // the tables version which added the column
+}
+
+func (c columnSpec) typeFor(dbox database.DbBox) string {
+	if dbox.IsPostgres {
+		return c.Postgres
+	} else if dbox.IsMysql {
+		return c.Mysql
+	} else if dbox.IsSqlite {
+		return c.Sqlite
+	}
+
+	panic("unknown database: " + dbox.Type)
+}
+
+type tableSpec struct {
+	Name       string
+	Columns    []columnSpec
+	PrimaryKey []string
+	Since      int // the tables version which added the table
+}
+
+// The tracking tables as created by mig init and upgraded by mig upgrade-tables
+var TABLE_SPECS = []tableSpec{
+	{
+		Name: "migrations",
+		Columns: []columnSpec{
+			{"id", "integer", "bigint", "serial", 1},
+			{"name", "character varying", "varchar", "varchar", 1},
+			{"batch", "integer", "int", "int4", 1},
+			{"migration_time", "timestamp with time zone", "timestamp", "timestamp", 1},
+			{"duration_ms", "bigint", "bigint", "integer", 2},
+			{"os_user", "character varying", "varchar", "varchar", 2},
+			{"hostname", "character varying", "varchar", "varchar", 2},
+			{"mig_version", "character varying", "varchar", "varchar", 2},
+			{"checksum", "character varying", "varchar", "varchar", 3},
+		},
+		PrimaryKey: []string{"id"},
+		Since:      1,
+	},
+	{
+		Name: "migrations_lock",
+		Columns: []columnSpec{
+			{"index", "integer", "bigint", "serial", 1},
+			{"is_locked", "integer", "int", "int4", 1},
+		},
+		PrimaryKey: []string{"index"},
+		Since:      1,
+	},
+	{
+		Name: "migrations_history",
+		Columns: []columnSpec{
+			{"id", "integer", "bigint", "integer", 2},
+			{"name", "character varying", "varchar", "varchar", 2},
+			{"direction", "character varying", "varchar", "varchar", 2},
+			{"batch", "integer", "int", "int4", 2},
+			{"migration_time", "timestamp with time zone", "timestamp", "timestamp", 2},
+			{"duration_ms", "bigint", "bigint", "integer", 2},
+			{"os_user", "character varying", "varchar", "varchar", 2},
+			{"hostname", "character varying", "varchar", "varchar", 2},
+			{"mig_version", "character varying", "varchar", "varchar", 2},
+		},
+		PrimaryKey: []string{"id"},
+		Since:      2,
+	},
+	{
+		Name: "mig_meta",
+		Columns: []columnSpec{
+			{"name", "character varying", "varchar", "varchar", 2},
+			{"value", "character varying", "varchar", "varchar", 2},
+		},
+		PrimaryKey: []string{"name"},
+		Since:      2,
+	},
+}
+
+// A discrepancy between the tracking tables and what mig expects
+type TableProblem struct {
+	Code    string `json:"code"` // machine-keyable problem code
+	Table   string `json:"table"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// Compares the tracking tables against the tables mig expects at the provided tables version.
+// Every discrepancy is returned, an error is only returned when the database can't be introspected.
+func CheckTables(dbox database.DbBox, version int) ([]TableProblem, error) {
+	var problems []TableProblem
+
+	for _, spec := range TABLE_SPECS {
+		if spec.Since > version {
+			continue
+		}
+
+		table, exists, err := dbox.DescribeTable(spec.Name)
+		if err != nil {
+			return problems, fmt.Errorf("unable to describe table %s: %w", spec.Name, err)
+		}
+
+		if !exists {
+			problems = append(problems, TableProblem{
+				Code:    "missing_table",
+				Table:   spec.Name,
+				Message: fmt.Sprintf("the %s table is missing", spec.Name),
+			})
+			continue
+		}
+
+		problems = append(problems, checkColumns(dbox, spec, table, version)...)
+
+		if strings.Join(table.PrimaryKey, ",") != strings.Join(spec.PrimaryKey, ",") {
+			problems = append(problems, TableProblem{
+				Code:    "invalid_primary_key",
+				Table:   spec.Name,
+				Message: fmt.Sprintf("expected %s to have a primary key of (%s) but found (%s)", spec.Name, strings.Join(spec.PrimaryKey, ", "), strings.Join(table.PrimaryKey, ", ")),
+			})
+		}
+
+		_, hasIndex := table.Column("index")
+		_, hasIsLocked := table.Column("is_locked")
+
+		if spec.Name == "migrations_lock" && hasIndex && hasIsLocked {
+			lockProblems, err := checkLockRow(dbox)
+			if err != nil {
+				return problems, err
+			}
+
+			problems = append(problems, lockProblems...)
+		}
+	}
+
+	return problems, nil
+}
+
+func checkColumns(dbox database.DbBox, spec tableSpec, table database.Table, version int) []TableProblem {
+	var problems []TableProblem
+
+	for _, expected := range spec.Columns {
+		if expected.Since > version {
+			continue
+		}
+
+		column, ok := table.Column(expected.Name)
+		if !ok {
+			problems = append(problems, TableProblem{
+				Code:    "missing_column",
+				Table:   spec.Name,
+				Column:  expected.Name,
+				Message: fmt.Sprintf("the %s.%s column is missing", spec.Name, expected.Name),
+			})
+			continue
+		}
+
+		if column.Type != expected.typeFor(dbox) {
+			problems = append(problems, TableProblem{
+				Code:    "invalid_column_type",
+				Table:   spec.Name,
+				Column:  expected.Name,
+				Message: fmt.Sprintf("expected %s.%s of type %s but found %s", spec.Name, expected.Name, expected.typeFor(dbox), column.Type),
+			})
+		}
+	}
+
+	for _, column := range table.Columns {
+		found := false
+		for _, expected := range spec.Columns {
+			if column.Name == expected.Name {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			problems = append(problems, TableProblem{
+				Code:    "unexpected_column",
+				Table:   spec.Name,
+				Column:  column.Name,
+				Message: fmt.Sprintf("the %s.%s column isn't used by mig", spec.Name, column.Name),
+			})
+		}
+	}
+
+	return problems
+}
+
+// The lock table should contain a single row with an index of 1 and is_locked of 0 or 1
+func checkLockRow(dbox database.DbBox) ([]TableProblem, error) {
+	var problems []TableProblem
+
+	rows, err := dbox.Query(LOCK_ROWS)
+	if err != nil {
+		return problems, fmt.Errorf("unable to read the migrations_lock table: %w", err)
+	}
+
+	defer rows.Close()
+
+	count := 0
+
+	for rows.Next() {
+		var index, isLocked int
+
+		if err = rows.Scan(&index, &isLocked); err != nil {
+			return problems, err
+		}
+
+		count++
+
+		if index != 1 {
+			problems = append(problems, TableProblem{
+				Code:    "invalid_lock_row",
+				Table:   "migrations_lock",
+				Column:  "index",
+				Message: fmt.Sprintf("the migrations_lock table contains a row with an index of %d instead of 1", index),
+			})
+		}
+
+		if isLocked != 0 && isLocked != 1 {
+			problems = append(problems, TableProblem{
+				Code:    "invalid_lock_value",
+				Table:   "migrations_lock",
+				Column:  "is_locked",
+				Message: fmt.Sprintf("the migrations_lock table has an is_locked value of %d instead of 0 or 1", isLocked),
+			})
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return problems, err
+	}
+
+	if count == 0 {
+		problems = append(problems, TableProblem{
+			Code:    "missing_lock_row",
+			Table:   "migrations_lock",
+			Message: "the migrations_lock table is empty",
+		})
+	} else if count > 1 {
+		problems = append(problems, TableProblem{
+			Code:    "multiple_lock_rows",
+			Table:   "migrations_lock",
+			Message: fmt.Sprintf("the migrations_lock table contains %d rows instead of 1", count),
+		})
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tlhunter/mig/database"
+)
+
+func TestCheckTables(t *testing.T) {
+	dbox, err := database.Connect("sqlite::memory:", database.ConnectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer dbox.Db.Close()
+
+	// version 1 tables with a wrong type, an extra column, and two lock rows
+	_, err = dbox.Db.Exec(`CREATE TABLE migrations (
+		id serial NOT NULL,
+		name text NULL,
+		batch int4 NULL,
+		migration_time timestamp NULL,
+		extra int NULL,
+		CONSTRAINT migrations_pkey PRIMARY KEY (id)
+	);
+	CREATE TABLE migrations_lock ("index" serial NOT NULL, is_locked int4 NULL, CONSTRAINT migrations_lock_pkey PRIMARY KEY ("index"));
+	INSERT INTO migrations_lock ("index", is_locked) VALUES (1, 0), (2, 0);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := CheckTables(dbox, 1)
+	assert.NoError(t, err)
+
+	var codes []string
+	for _, problem := range problems {
+		codes = append(codes, problem.Code)
+	}
+
+	assert.Equal(t, []string{"invalid_column_type", "unexpected_column", "invalid_lock_row", "multiple_lock_rows"}, codes, "every problem is reported")
+
+	problems, err = CheckTables(dbox, 2)
+	assert.NoError(t, err)
+	assert.Contains(t, problems, TableProblem{
+		Code:    "missing_table",
+		Table:   "mig_meta",
+		Message: "the mig_meta table is missing",
+	}, "newer tables are only expected at newer versions")
+}
@@ -2,10 +2,13 @@ package migrations
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 )
 
-func ListFiles(directory string) ([]string, error) {
+// Lists the migrations in the directory. Files matching an excluded path, such as a schema dump kept
+// alongside the migrations, are skipped.
+func ListFiles(directory string, exclude ...string) ([]string, error) {
 	var migFiles []string
 
 	files, err := os.ReadDir(directory)
@@ -20,8 +23,31 @@ func ListFiles(directory string) ([]string, error) {
 			continue
 		}
 
+		if isExcluded(filepath.Join(directory, name), exclude) {
+			continue
+		}
+
 		migFiles = append(migFiles, entry.Name())
 	}
 
 	return migFiles, nil
 }
+
+func isExcluded(filename string, exclude []string) bool {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+
+	for _, excluded := range exclude {
+		if excluded == "" {
+			continue
+		}
+
+		if excludedPath, err := filepath.Abs(excluded); err == nil && excludedPath == path {
+			return true
+		}
+	}
+
+	return false
+}
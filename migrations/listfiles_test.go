@@ -1,6 +1,7 @@
 package migrations
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -19,3 +20,19 @@ func TestListFiles(t *testing.T) {
 		"20230101120107_add_email_to_users.sql",
 	}, "file listing not matching")
 }
+
+func TestListFilesExcludesSchema(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"1_a.sql", "2_b.sql", "schema.sql"} {
+		assert.NoError(t, os.WriteFile(dir+"/"+name, []byte(""), 0644))
+	}
+
+	files, err := ListFiles(dir, dir+"/./schema.sql")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1_a.sql", "2_b.sql"}, files, "the schema dump isn't a migration")
+
+	files, err = ListFiles(dir, "")
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+}
@@ -1,6 +1,7 @@
 package migrations
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/tlhunter/mig/database"
@@ -11,6 +12,52 @@ type MigrationRow struct {
 	Name  string     `json:"name"`
 	Batch int        `json:"batch,omitempty"`
 	Time  *time.Time `json:"time,omitempty"`
+
+	DurationMs *int64 `json:"duration_ms,omitempty"` // nil for migrations run by older versions of mig
+	OsUser     string `json:"os_user,omitempty"`
+	Hostname   string `json:"hostname,omitempty"`
+	MigVersion string `json:"mig_version,omitempty"`
+	Checksum   string `json:"checksum,omitempty"` // empty for migrations run by older versions of mig
+
+	RenamedFrom string         `json:"renamed_from,omitempty"` // name stored in the migrations table when the file was renamed
+	Replaced    []MigrationRow `json:"replaced,omitempty"`     // rows of the migrations combined into this one by mig squash
+}
+
+// The name of the migration in the tracking tables, which differs from the file when it was renamed
+func (m MigrationRow) StoredName() string {
+	if m.RenamedFrom != "" {
+		return m.RenamedFrom
+	}
+
+	return m.Name
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// Scans a row of id, name, batch, migration_time, duration_ms, os_user, hostname, mig_version, checksum
+func scanRow(row scanner) (MigrationRow, error) {
+	var migration MigrationRow
+	var migrationTime time.Time
+	var durationMs sql.NullInt64
+	var osUser, hostname, migVersion, checksum sql.NullString
+
+	err := row.Scan(&migration.Id, &migration.Name, &migration.Batch, &migrationTime, &durationMs, &osUser, &hostname, &migVersion, &checksum)
+	if err != nil {
+		return migration, err
+	}
+
+	migration.Time = &migrationTime
+	if durationMs.Valid {
+		migration.DurationMs = &durationMs.Int64
+	}
+	migration.OsUser = osUser.String
+	migration.Hostname = hostname.String
+	migration.MigVersion = migVersion.String
+	migration.Checksum = checksum.String
+
+	return migration, nil
 }
 
 func ListRows(dbox database.DbBox) ([]MigrationRow, error) {
@@ -20,7 +67,22 @@ func ListRows(dbox database.DbBox) ([]MigrationRow, error) {
 		panic("unknown database: " + dbox.Type)
 	}
 
-	rows, err := dbox.Db.Query("SELECT id, name, batch, migration_time FROM migrations ORDER BY id ASC;") // same for MySQL, Postgres, Sqlite
+	// tables created by older versions of mig lack some of the columns, they're read as NULL
+	columns := "id, name, batch, migration_time"
+	if RunColumnsExist(dbox) {
+		columns += ", duration_ms, os_user, hostname, mig_version"
+	} else {
+		columns += ", NULL, NULL, NULL, NULL"
+	}
+	if ChecksumExists(dbox) {
+		columns += ", checksum"
+	} else {
+		columns += ", NULL"
+	}
+
+	query := "SELECT " + columns + " FROM migrations ORDER BY id ASC;" // same for MySQL, Postgres, Sqlite
+
+	rows, err := dbox.Query(database.QueryBox{Postgres: query, Mysql: query, Sqlite: query})
 	if err != nil {
 		return migRows, err
 	}
@@ -28,22 +90,12 @@ func ListRows(dbox database.DbBox) ([]MigrationRow, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var id int
-		var name string
-		var batch int
-		var time time.Time
-
-		err = rows.Scan(&id, &name, &batch, &time)
+		migration, err := scanRow(rows)
 		if err != nil {
 			return migRows, err
 		}
 
-		migRows = append(migRows, MigrationRow{
-			Id:    id,
-			Name:  name,
-			Batch: batch,
-			Time:  &time,
-		})
+		migRows = append(migRows, migration)
 	}
 
 	return migRows, nil
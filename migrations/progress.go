@@ -2,6 +2,9 @@ package migrations
 
 import (
 	"errors"
+	"os"
+	"os/user"
+	"time"
 
 	"github.com/tlhunter/mig/database"
 )
@@ -22,6 +25,21 @@ var (
 		Mysql:    `DELETE FROM migrations WHERE id = ? AND name = ?;`,
 		Sqlite:   `DELETE FROM migrations WHERE id = ? AND name = ?;`,
 	}
+	INSERT = database.QueryBox{
+		Postgres: `INSERT INTO migrations (id, name, batch, migration_time, duration_ms, os_user, hostname, mig_version, checksum) VALUES ($1, $2, $3, NOW(), $4, $5, $6, $7, $8) RETURNING id, name, batch, migration_time, duration_ms, os_user, hostname, mig_version, checksum;`,
+		Mysql:    `INSERT INTO migrations (id, name, batch, migration_time, duration_ms, os_user, hostname, mig_version, checksum) VALUES (?, ?, ?, NOW(), ?, ?, ?, ?, ?);`,
+		Sqlite:   `INSERT INTO migrations (id, name, batch, migration_time, duration_ms, os_user, hostname, mig_version, checksum) VALUES (?, ?, ?, CURRENT_TIMESTAMP, ?, ?, ?, ?, ?) RETURNING id, name, batch, migration_time, duration_ms, os_user, hostname, mig_version, checksum;`,
+	}
+	SELECT_BY_ID = database.QueryBox{
+		Postgres: `SELECT id, name, batch, migration_time, duration_ms, os_user, hostname, mig_version, checksum FROM migrations WHERE id = $1;`,
+		Mysql:    `SELECT id, name, batch, migration_time, duration_ms, os_user, hostname, mig_version, checksum FROM migrations WHERE id = ?;`,
+		Sqlite:   `SELECT id, name, batch, migration_time, duration_ms, os_user, hostname, mig_version, checksum FROM migrations WHERE id = ?;`,
+	}
+	INSERT_HISTORY = database.QueryBox{
+		Postgres: `INSERT INTO migrations_history (name, direction, batch, migration_time, duration_ms, os_user, hostname, mig_version) VALUES ($1, $2, $3, NOW(), $4, $5, $6, $7);`,
+		Mysql:    `INSERT INTO migrations_history (name, direction, batch, migration_time, duration_ms, os_user, hostname, mig_version) VALUES (?, ?, ?, NOW(), ?, ?, ?, ?);`,
+		Sqlite:   `INSERT INTO migrations_history (name, direction, batch, migration_time, duration_ms, os_user, hostname, mig_version) VALUES (?, ?, ?, CURRENT_TIMESTAMP, ?, ?, ?, ?);`,
+	}
 	COUNT = database.QueryBox{
 		Postgres: `SELECT COUNT(*) AS count FROM migrations;`,
 		Mysql:    `SELECT COUNT(*) AS count FROM migrations;`,
@@ -34,84 +52,102 @@ type BatchAndId struct {
 	Id    int
 }
 
-// up
-func AddMigration(dbox database.DbBox, migrationName string) (MigrationRow, error) {
-	var migration MigrationRow
+// Describes a single run of a migration, stored in the migrations and migrations_history tables
+type Execution struct {
+	Duration   time.Duration
+	OsUser     string
+	Hostname   string
+	MigVersion string
+	Checksum   string // checksum of the migration file, only stored in the migrations table
+}
 
-	highest, err := GetHighestValues(dbox)
-	if err != nil {
-		return migration, err
+// Describes a run by the current process, the OS user and hostname are left empty when they can't be determined
+func NewExecution(duration time.Duration, migVersion string, checksum string) Execution {
+	execution := Execution{
+		Duration:   duration,
+		MigVersion: migVersion,
+		Checksum:   checksum,
 	}
 
-	if dbox.IsPostgres {
-		migration, err = postgresAddMigration(dbox, highest.Id, migrationName, highest.Batch)
-	} else if dbox.IsMysql {
-		migration, err = mysqlAddMigration(dbox, highest.Id, migrationName, highest.Batch)
-	} else if dbox.IsSqlite {
-		migration, err = sqliteAddMigration(dbox, highest.Id, migrationName, highest.Batch)
-	} else {
-		panic("unknown database: " + dbox.Type)
+	if current, err := user.Current(); err == nil {
+		execution.OsUser = current.Username
 	}
 
-	if err != nil {
-		return migration, err
+	if hostname, err := os.Hostname(); err == nil {
+		execution.Hostname = hostname
 	}
 
-	return migration, nil
+	return execution
 }
 
-func postgresAddMigration(dbox database.DbBox, id int, name string, batchId int) (MigrationRow, error) {
-	var migration MigrationRow
-
-	err := dbox.Db.
-		QueryRow(`INSERT INTO migrations (id, name, batch, migration_time) VALUES ($1, $2, $3, NOW()) RETURNING id, name, batch, migration_time;`, id, name, batchId).
-		Scan(&migration.Id, &migration.Name, &migration.Batch, &migration.Time)
+func (e Execution) args() []any {
+	return []any{e.Duration.Milliseconds(), e.OsUser, e.Hostname, e.MigVersion}
+}
 
-	return migration, err
+func recordHistory(dbox database.DbBox, name string, direction string, batch int, execution Execution) error {
+	_, err := dbox.Exec(INSERT_HISTORY, append([]any{name, direction, batch}, execution.args()...)...)
+	return err
 }
 
-func mysqlAddMigration(dbox database.DbBox, id int, name string, batchId int) (MigrationRow, error) {
-	var migration MigrationRow
+func insertArgs(id int, name string, batchId int, execution Execution) []any {
+	return append(append([]any{id, name, batchId}, execution.args()...), execution.Checksum)
+}
 
-	tx, err := dbox.Db.Begin()
+// up
+func AddMigration(dbox database.DbBox, migrationName string, execution Execution) (MigrationRow, error) {
+	highest, err := GetHighestValues(dbox)
 	if err != nil {
-		return migration, err
+		return MigrationRow{}, err
 	}
 
-	defer tx.Rollback()
+	return AddMigrationWithBatch(dbox, migrationName, highest.Batch, execution)
+}
+
+func postgresAddMigration(dbox database.DbBox, id int, name string, batchId int, execution Execution) (MigrationRow, error) {
+	return scanRow(dbox.QueryRow(INSERT, insertArgs(id, name, batchId, execution)...))
+}
+
+func mysqlAddMigration(dbox database.DbBox, id int, name string, batchId int, execution Execution) (MigrationRow, error) {
+	var migration MigrationRow
+
+	// the insert joins the transaction of the box when there is one
+	tx := dbox
+	if !dbox.InTx() {
+		var err error
+		tx, err = dbox.Begin(database.TxSettings{})
+		if err != nil {
+			return migration, err
+		}
+
+		defer tx.Rollback()
+	}
 
-	_, err = tx.Exec("INSERT INTO migrations (id, name, batch, migration_time) VALUES (?, ?, ?, NOW());", id, name, batchId)
+	_, err := tx.Exec(INSERT, insertArgs(id, name, batchId, execution)...)
 	if err != nil {
 		return migration, err
 	}
 
 	// Technically, the only value we need is the time, since that's the only value that the database determins
-	err = tx.
-		QueryRow("SELECT id, name, batch, migration_time FROM migrations WHERE id = ?;", id).
-		Scan(&migration.Id, &migration.Name, &migration.Batch, &migration.Time)
+	migration, err = scanRow(tx.QueryRow(SELECT_BY_ID, id))
 	if err != nil {
 		return migration, err
 	}
 
-	if err = tx.Commit(); err != nil {
-		return migration, err
+	if !dbox.InTx() {
+		if err = tx.Commit(); err != nil {
+			return migration, err
+		}
 	}
 
 	return migration, nil
 }
 
-func sqliteAddMigration(dbox database.DbBox, id int, name string, batchId int) (MigrationRow, error) {
-	var migration MigrationRow
-
-	err := dbox.Db.
-		QueryRow(`INSERT INTO migrations (id, name, batch, migration_time) VALUES (?, ?, ?, CURRENT_TIMESTAMP) RETURNING id, name, batch, migration_time;`, id, name, batchId).
-		Scan(&migration.Id, &migration.Name, &migration.Batch, &migration.Time)
-
-	return migration, err
+func sqliteAddMigration(dbox database.DbBox, id int, name string, batchId int, execution Execution) (MigrationRow, error) {
+	return scanRow(dbox.QueryRow(INSERT, insertArgs(id, name, batchId, execution)...))
 }
 
 // upto, all
-func AddMigrationWithBatch(dbox database.DbBox, migrationName string, batch int) (MigrationRow, error) {
+func AddMigrationWithBatch(dbox database.DbBox, migrationName string, batch int, execution Execution) (MigrationRow, error) {
 	var migration MigrationRow
 
 	highest, err := GetHighestValues(dbox)
@@ -120,11 +156,11 @@ func AddMigrationWithBatch(dbox database.DbBox, migrationName string, batch int)
 	}
 
 	if dbox.IsPostgres {
-		migration, err = postgresAddMigration(dbox, highest.Id, migrationName, batch)
+		migration, err = postgresAddMigration(dbox, highest.Id, migrationName, batch, execution)
 	} else if dbox.IsMysql {
-		migration, err = mysqlAddMigration(dbox, highest.Id, migrationName, batch)
+		migration, err = mysqlAddMigration(dbox, highest.Id, migrationName, batch, execution)
 	} else if dbox.IsSqlite {
-		migration, err = sqliteAddMigration(dbox, highest.Id, migrationName, batch)
+		migration, err = sqliteAddMigration(dbox, highest.Id, migrationName, batch, execution)
 	} else {
 		panic("unknown database: " + dbox.Type)
 	}
@@ -133,11 +169,15 @@ func AddMigrationWithBatch(dbox database.DbBox, migrationName string, batch int)
 		return migration, err
 	}
 
+	if err = recordHistory(dbox, migrationName, "up", batch, execution); err != nil {
+		return migration, err
+	}
+
 	return migration, nil
 }
 
 // down
-func RemoveMigration(dbox database.DbBox, migration string, id int) error {
+func RemoveMigration(dbox database.DbBox, migration string, id int, batch int, execution Execution) error {
 	// Ensure that the provided migration is the final migration
 	// If it's not then fail
 	var lastId int
@@ -166,7 +206,7 @@ func RemoveMigration(dbox database.DbBox, migration string, id int) error {
 		return errors.New("Unable to remove migration from migrations table")
 	}
 
-	return nil
+	return recordHistory(dbox, migration, "down", batch, execution)
 }
 
 func GetHighestValues(dbox database.DbBox) (BatchAndId, error) {
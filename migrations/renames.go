@@ -0,0 +1,86 @@
+package migrations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// Maps the old name of each renamed migration file to its new name, stored in the migrations directory
+const RENAMES_FILE = "renames.json"
+
+// Reads the rename manifest, which is optional. A file renamed more than once is mapped to its latest name.
+func ReadRenames(directory string) (map[string]string, error) {
+	renames := map[string]string{}
+
+	contents, err := os.ReadFile(directory + "/" + RENAMES_FILE)
+	if errors.Is(err, fs.ErrNotExist) {
+		return renames, nil
+	} else if err != nil {
+		return renames, err
+	}
+
+	var manifest map[string]string
+	if err = json.Unmarshal(contents, &manifest); err != nil {
+		return renames, fmt.Errorf("unable to parse %s: %w", RENAMES_FILE, err)
+	}
+
+	for from, to := range manifest {
+		latest := to
+
+		for steps := 0; ; steps++ {
+			next, ok := manifest[latest]
+			if !ok {
+				break
+			}
+
+			if steps >= len(manifest) {
+				return renames, fmt.Errorf("%s renames %s in a cycle", RENAMES_FILE, from)
+			}
+
+			latest = next
+		}
+
+		renames[from] = latest
+	}
+
+	return renames, nil
+}
+
+// Gives applied migrations the new name of their file. A migration keeps its name when a migration with
+// the new name was also applied. The rows are then sorted by name to line up with the files.
+func applyRenames(migRows []MigrationRow, renames map[string]string) []MigrationRow {
+	if len(renames) == 0 {
+		return migRows
+	}
+
+	taken := map[string]bool{}
+	for _, row := range migRows {
+		taken[row.Name] = true
+	}
+
+	renamed := false
+
+	for i, row := range migRows {
+		to, ok := renames[row.Name]
+		if !ok || taken[to] {
+			continue
+		}
+
+		taken[to] = true
+		migRows[i].RenamedFrom = row.Name
+		migRows[i].Name = to
+		renamed = true
+	}
+
+	if renamed {
+		sort.SliceStable(migRows, func(i, j int) bool {
+			return migRows[i].Name < migRows[j].Name
+		})
+	}
+
+	return migRows
+}
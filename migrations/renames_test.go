@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tlhunter/mig/config"
+	"github.com/tlhunter/mig/database"
+)
+
+func TestReadRenames(t *testing.T) {
+	dir := t.TempDir()
+
+	renames, err := ReadRenames(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, renames, "the manifest is optional")
+
+	err = os.WriteFile(dir+"/"+RENAMES_FILE, []byte(`{"a.sql": "b.sql", "b.sql": "c.sql"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renames, err = ReadRenames(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.sql": "c.sql", "b.sql": "c.sql"}, renames, "a file renamed twice maps to the latest name")
+
+	err = os.WriteFile(dir+"/"+RENAMES_FILE, []byte(`{"a.sql": "b.sql", "b.sql": "a.sql"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ReadRenames(dir)
+	assert.Error(t, err)
+}
+
+func TestApplyRenames(t *testing.T) {
+	rows := applyRenames([]MigrationRow{
+		{Id: 1, Name: "1_a.sql"},
+		{Id: 2, Name: "2_b.sql"},
+		{Id: 3, Name: "3_c.sql"},
+	}, map[string]string{
+		"1_a.sql": "4_a.sql",
+		"2_b.sql": "3_c.sql",
+	})
+
+	assert.Equal(t, []MigrationRow{
+		{Id: 2, Name: "2_b.sql"},
+		{Id: 3, Name: "3_c.sql"},
+		{Id: 1, Name: "4_a.sql", RenamedFrom: "1_a.sql"},
+	}, rows, "a rename onto an applied migration is ignored")
+}
+
+func TestStatusLastAfterRename(t *testing.T) {
+	dir := t.TempDir()
+
+	dbox, err := database.Connect("sqlite::memory:", database.ConnectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer dbox.Db.Close()
+
+	if err = CreateTables(dbox); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"1_a.sql", "2_b.sql"} {
+		if _, err = AddMigration(dbox, name, NewExecution(0, "test", "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the first migration is renamed so that it sorts after the second
+	for _, name := range []string{"2_b.sql", "3_a.sql"} {
+		if err = os.WriteFile(dir+"/"+name, []byte("--BEGIN MIGRATION UP--\n--END MIGRATION UP--\n--BEGIN MIGRATION DOWN--\n--END MIGRATION DOWN--\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = os.WriteFile(dir+"/"+RENAMES_FILE, []byte(`{"1_a.sql": "3_a.sql"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := GetStatus(config.MigConfig{Migrations: dir}, dbox)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, status.Applied)
+	assert.Equal(t, "2_b.sql", status.Last.Name, "the last migration is the most recently applied rather than the last by name")
+}
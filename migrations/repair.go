@@ -0,0 +1,129 @@
+package migrations
+
+import (
+	"github.com/tlhunter/mig/database"
+)
+
+var (
+	RESTORE_BACKUP = database.QueryBox{
+		Postgres: `ALTER TABLE migrations_backup RENAME TO migrations;`,
+		Mysql:    `ALTER TABLE migrations_backup RENAME TO migrations;`,
+		Sqlite:   `ALTER TABLE migrations_backup RENAME TO migrations;`,
+	}
+	CLEAR_LOCK_ROWS = database.QueryBox{
+		Postgres: `DELETE FROM migrations_lock;`,
+		Mysql:    `DELETE FROM migrations_lock;`,
+		Sqlite:   `DELETE FROM migrations_lock;`,
+	}
+	RENUMBER = database.QueryBox{
+		Postgres: `UPDATE migrations SET id = $1 WHERE id = $2;`,
+		Mysql:    `UPDATE migrations SET id = ? WHERE id = ?;`,
+		Sqlite:   `UPDATE migrations SET id = ? WHERE id = ?;`,
+	}
+	RENAME = database.QueryBox{
+		Postgres: `UPDATE migrations SET name = $1 WHERE id = $2;`,
+		Mysql:    `UPDATE migrations SET name = ? WHERE id = ?;`,
+		Sqlite:   `UPDATE migrations SET name = ? WHERE id = ?;`,
+	}
+	RENAME_HISTORY = database.QueryBox{
+		Postgres: `UPDATE migrations_history SET name = $1 WHERE name = $2;`,
+		Mysql:    `UPDATE migrations_history SET name = ? WHERE name = ?;`,
+		Sqlite:   `UPDATE migrations_history SET name = ? WHERE name = ?;`,
+	}
+	SET_CHECKSUM = database.QueryBox{
+		Postgres: `UPDATE migrations SET checksum = $1 WHERE id = $2;`,
+		Mysql:    `UPDATE migrations SET checksum = ? WHERE id = ?;`,
+		Sqlite:   `UPDATE migrations SET checksum = ? WHERE id = ?;`,
+	}
+)
+
+// Whether a migrations_backup table was left behind, e.g. by a manual repair of the migrations table
+func BackupExists(dbox database.DbBox) (bool, error) {
+	return dbox.TableExists("migrations_backup")
+}
+
+// Renames the migrations_backup table to migrations, the migrations table must not exist
+func RestoreBackup(dbox database.DbBox) error {
+	_, err := dbox.Exec(RESTORE_BACKUP)
+	return err
+}
+
+// Replaces the contents of the lock table with a single unlocked row
+func ResetLockRows(dbox database.DbBox) error {
+	tx, err := dbox.Begin(database.TxSettings{})
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(CLEAR_LOCK_ROWS); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(INSERT_LOCK_ROW); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Renumbers the migrations ids to 1..n while keeping their order. It should only be called while holding the lock.
+func RenumberIds(dbox database.DbBox) error {
+	rows, err := ListRows(dbox)
+	if err != nil {
+		return err
+	}
+
+	tx, err := dbox.Begin(database.TxSettings{})
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	// ids only ever shrink and rows are visited in ascending order so an id is never taken twice
+	for i, row := range rows {
+		if row.Id == i+1 {
+			continue
+		}
+
+		if _, err = tx.Exec(RENUMBER, i+1, row.Id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Renames an applied migration, including its history, e.g. after the file was renamed.
+// It should only be called while holding the lock.
+func RenameMigration(dbox database.DbBox, id int, from string, to string) error {
+	// probing for a missing table aborts a postgres transaction so it's done before beginning one
+	hasHistory := historyExists(dbox)
+
+	tx, err := dbox.Begin(database.TxSettings{})
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(RENAME, to, id); err != nil {
+		return err
+	}
+
+	if hasHistory {
+		if _, err = tx.Exec(RENAME_HISTORY, to, from); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Stores the checksum of an applied migration which was run by an older version of mig
+func SetChecksum(dbox database.DbBox, id int, checksum string) error {
+	_, err := dbox.Exec(SET_CHECKSUM, checksum, id)
+	return err
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tlhunter/mig/database"
+)
+
+func TestRenumberAndRename(t *testing.T) {
+	dbox, err := database.Connect("sqlite::memory:", database.ConnectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer dbox.Db.Close()
+
+	if err = CreateTables(dbox); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a.sql", "b.sql", "c.sql"} {
+		if _, err = AddMigration(dbox, name, NewExecution(0, "test", name+"-checksum")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = dbox.Db.Exec(`DELETE FROM migrations WHERE id = 2; UPDATE migrations SET id = 7 WHERE id = 3;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, RenumberIds(dbox))
+	assert.NoError(t, RenameMigration(dbox, 2, "c.sql", "d.sql"))
+
+	rows, err := ListRows(dbox)
+	assert.NoError(t, err)
+
+	var ids []int
+	var names []string
+	for _, row := range rows {
+		ids = append(ids, row.Id)
+		names = append(names, row.Name)
+	}
+
+	assert.Equal(t, []int{1, 2}, ids, "ids are renumbered in order")
+	assert.Equal(t, []string{"a.sql", "d.sql"}, names)
+	assert.Equal(t, "c.sql-checksum", rows[1].Checksum, "renaming keeps the checksum")
+
+	runs, err := ListRuns(dbox)
+	assert.NoError(t, err)
+	assert.Len(t, runs["d.sql"], 1, "the history follows the rename")
+	assert.Empty(t, runs["c.sql"])
+}
@@ -0,0 +1,110 @@
+package migrations
+
+import (
+	"os"
+	"strings"
+
+	"github.com/tlhunter/mig/database"
+)
+
+const (
+	SCHEMA_HEADER  = "-- Generated by mig dump-schema, do not edit by hand"
+	APPLIED_PREFIX = "-- mig-schema applied: " // a plain comment rather than a directive, which the parser would reject
+)
+
+// The tracking tables are left out of schema dumps
+func TrackingTables() []string {
+	var tables []string
+
+	for _, spec := range TABLE_SPECS {
+		tables = append(tables, spec.Name)
+	}
+
+	return tables
+}
+
+// Renders the schema of the database along with the migrations it reflects, one per line so that
+// the file changes by a line for every new migration
+func DumpSchema(dbox database.DbBox, status MigrationStatus) (string, error) {
+	statements, err := dbox.DumpSchema(TrackingTables())
+	if err != nil {
+		return "", err
+	}
+
+	var dump strings.Builder
+
+	dump.WriteString(SCHEMA_HEADER + "\n")
+
+	for _, entry := range status.History {
+		if entry.Status == "applied" || entry.Status == "missing" {
+			dump.WriteString(APPLIED_PREFIX + entry.Migration.Name + "\n")
+		}
+	}
+
+	for _, statement := range statements {
+		dump.WriteString("\n" + statement + "\n")
+	}
+
+	return dump.String(), nil
+}
+
+type SchemaDump struct {
+	Applied []string // the migrations reflected by the schema
+	Sql     string
+}
+
+func ReadSchema(filename string) (SchemaDump, error) {
+	var dump SchemaDump
+
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return dump, err
+	}
+
+	dump.Sql = string(contents)
+
+	for _, line := range strings.Split(dump.Sql, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, APPLIED_PREFIX) {
+			dump.Applied = append(dump.Applied, strings.TrimSpace(strings.TrimPrefix(line, APPLIED_PREFIX)))
+		}
+	}
+
+	return dump, nil
+}
+
+// Applies a schema dump and records the migrations it reflects as a single batch, in one transaction
+// except for mysql which implicitly commits DDL. It should only be called while holding the lock.
+func LoadSchema(dbox database.DbBox, dump SchemaDump, directory string, migVersion string) ([]MigrationRow, error) {
+	var loaded []MigrationRow
+
+	highest, err := GetHighestValues(dbox)
+	if err != nil {
+		return loaded, err
+	}
+
+	tx, err := dbox.Begin(database.TxSettings{})
+	if err != nil {
+		return loaded, err
+	}
+
+	defer tx.Rollback()
+
+	// the comments of the dump, including the applied lines, are ignored by every database
+	if _, err = tx.Exec(database.QueryBox{Postgres: dump.Sql, Mysql: dump.Sql, Sqlite: dump.Sql}); err != nil {
+		return loaded, err
+	}
+
+	for _, name := range dump.Applied {
+		// the checksum is unknown when the file was squashed or removed since the dump
+		checksum, _ := FileChecksum(directory + "/" + name)
+
+		migration, err := AddMigrationWithBatch(tx, name, highest.Batch, NewExecution(0, migVersion, checksum))
+		if err != nil {
+			return loaded, err
+		}
+
+		loaded = append(loaded, migration)
+	}
+
+	return loaded, tx.Commit()
+}
@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tlhunter/mig/database"
+)
+
+func TestDumpAndLoadSchema(t *testing.T) {
+	source, err := database.Connect("sqlite::memory:", database.ConnectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer source.Db.Close()
+
+	if err = CreateTables(source); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = source.Db.Exec(`CREATE TABLE users (id int, email varchar(255));`); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := DumpSchema(source, MigrationStatus{History: []MigrationRowStatus{
+		{Migration: MigrationRow{Name: "1_users.sql"}, Status: "applied"},
+		{Migration: MigrationRow{Name: "2_emails.sql"}, Status: "unapplied"},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, SCHEMA_HEADER+"\n-- mig-schema applied: 1_users.sql\n\nCREATE TABLE users (id int, email varchar(255));\n", contents, "the tracking tables are left out")
+
+	filename := t.TempDir() + "/schema.sql"
+	if err = os.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dump, err := ReadSchema(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1_users.sql"}, dump.Applied)
+
+	target, err := database.Connect("sqlite::memory:", database.ConnectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer target.Db.Close()
+
+	if err = CreateTables(target); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadSchema(target, dump, t.TempDir(), "test")
+	assert.NoError(t, err)
+	assert.Len(t, loaded, 1)
+	assert.Equal(t, "1_users.sql", loaded[0].Name)
+
+	exists, err := target.TableExists("users")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
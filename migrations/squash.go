@@ -0,0 +1,289 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	ARCHIVE_DIR     = "archive" // subdirectory of the migrations directory containing squashed migrations
+	SQUASHED_SUFFIX = "_squashed.sql"
+)
+
+type Squashed struct {
+	Filename string   `json:"filename"`
+	Replaces []string `json:"replaces"`
+}
+
+// Combines every migration up to and including the target into a single migration and moves the
+// originals to the archive directory. The new migration records the migrations it replaces, including
+// those replaced by an earlier squash, so databases which applied them are still up to date. The tags of the
+// originals and their requirements outside of the squashed range are kept. Excluded files are passed along to ListFiles.
+func Squash(directory string, target string, exclude ...string) (Squashed, error) {
+	var squashed Squashed
+
+	migFiles, err := ListFiles(directory, exclude...)
+	if err != nil {
+		return squashed, err
+	}
+
+	end := -1
+	for i, migFile := range migFiles {
+		if migFile == target {
+			end = i
+		}
+	}
+
+	if end == -1 {
+		return squashed, fmt.Errorf("the migration %s doesn't exist", target)
+	}
+
+	if end == 0 {
+		return squashed, errors.New("at least two migrations are needed to squash")
+	}
+
+	upTx, downTx := true, true
+	up, down := "", ""
+	var requires, tags []string
+
+	for _, migFile := range migFiles[:end+1] {
+		pair, err := GetQueriesFromFile(directory + "/" + migFile)
+		if err != nil {
+			return squashed, fmt.Errorf("unable to parse %s: %w", migFile, err)
+		}
+
+		if len(pair.Directives.Envs) > 0 {
+			return squashed, fmt.Errorf("%s only runs in some environments and can't be squashed", migFile)
+		}
+
+		if pair.Directives.HasTxSettings() {
+			return squashed, fmt.Errorf("%s has transaction directives and can't be squashed", migFile)
+		}
+
+		if pair.Directives.Timeout > 0 {
+			return squashed, fmt.Errorf("%s has a timeout directive and can't be squashed", migFile)
+		}
+
+		if migFile == target && len(pair.Directives.Replaces) > 0 {
+			return squashed, fmt.Errorf("%s has already been squashed", migFile)
+		}
+
+		squashed.Replaces = append(squashed.Replaces, pair.Directives.Replaces...)
+		squashed.Replaces = append(squashed.Replaces, migFile)
+
+		requires = appendMissing(requires, pair.Directives.Requires...)
+		tags = appendMissing(tags, pair.Directives.Tags...)
+
+		// a single migration without a transaction means the combined block can't use one
+		upTx = upTx && pair.UpTx
+		downTx = downTx && pair.DownTx
+
+		up += "-- " + migFile + "\n" + pair.Up
+		down = "-- " + migFile + "\n" + pair.Down + down
+	}
+
+	prefix, _, found := strings.Cut(target, "_")
+	if !found {
+		prefix = strings.TrimSuffix(target, ".sql")
+	}
+
+	squashed.Filename = prefix + SQUASHED_SUFFIX
+
+	if _, err = os.Stat(directory + "/" + squashed.Filename); !errors.Is(err, fs.ErrNotExist) {
+		return squashed, fmt.Errorf("the file %s already exists", squashed.Filename)
+	}
+
+	for _, migFile := range migFiles[:end+1] {
+		if _, err = os.Stat(directory + "/" + ARCHIVE_DIR + "/" + migFile); !errors.Is(err, fs.ErrNotExist) {
+			return squashed, fmt.Errorf("the file %s/%s already exists", ARCHIVE_DIR, migFile)
+		}
+	}
+
+	beginUp, beginDown := DELIM_BEGIN_UP, DELIM_BEGIN_DOWN
+	if !upTx {
+		beginUp = DELIM_BEGIN_UP_NO_TX
+	}
+	if !downTx {
+		beginDown = DELIM_BEGIN_DOWN_NO_TX
+	}
+
+	contents := DIRECTIVE_PREFIX + "replaces=" + strings.Join(squashed.Replaces, ",") + "\n"
+
+	// a requirement within the range is met by the squashed migration itself
+	var external []string
+	for _, required := range requires {
+		met := false
+		for _, replaced := range squashed.Replaces {
+			if satisfies(replaced, required) {
+				met = true
+				break
+			}
+		}
+
+		if !met {
+			external = append(external, required)
+		}
+	}
+
+	if len(external) > 0 {
+		contents += DIRECTIVE_PREFIX + "requires=" + strings.Join(external, ",") + "\n"
+	}
+
+	if len(tags) > 0 {
+		contents += DIRECTIVE_PREFIX + "tags=" + strings.Join(tags, ",") + "\n"
+	}
+
+	contents += beginUp + "\n" + up + DELIM_END_UP + "\n" +
+		beginDown + "\n" + down + DELIM_END_DOWN + "\n"
+
+	// the new migration is staged under a hidden name, which ListFiles skips, until the originals are archived
+	staged := directory + "/." + squashed.Filename
+	archive := directory + "/" + ARCHIVE_DIR
+
+	if err = os.WriteFile(staged, []byte(contents), 0644); err != nil {
+		return squashed, err
+	}
+
+	_, err = os.Stat(archive)
+	createdArchive := errors.Is(err, fs.ErrNotExist)
+
+	var archived []string
+
+	// puts back everything done so far so that a failure leaves the directory as it was
+	undo := func() {
+		for i := len(archived) - 1; i >= 0; i-- {
+			os.Rename(archive+"/"+archived[i], directory+"/"+archived[i])
+		}
+
+		if createdArchive {
+			os.Remove(archive)
+		}
+
+		os.Remove(staged)
+	}
+
+	if err = os.MkdirAll(archive, 0755); err != nil {
+		undo()
+		return squashed, err
+	}
+
+	for _, migFile := range migFiles[:end+1] {
+		if err = os.Rename(directory+"/"+migFile, archive+"/"+migFile); err != nil {
+			undo()
+			return squashed, err
+		}
+
+		archived = append(archived, migFile)
+	}
+
+	if err = os.Rename(staged, directory+"/"+squashed.Filename); err != nil {
+		undo()
+		return squashed, err
+	}
+
+	return squashed, nil
+}
+
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			list = append(list, value)
+		}
+	}
+
+	return list
+}
+
+// Replaces the rows of migrations which have since been squashed with a single row for the squashed migration.
+// A database is up to date with a squash when it applied the last of the replaced migrations, since the
+// others were applied before it. The files are only read when some of the applied migrations are missing.
+func applySquashes(directory string, migFiles []string, migRows []MigrationRow) []MigrationRow {
+	applied := map[string]bool{}
+	for _, row := range migRows {
+		applied[row.Name] = true
+	}
+
+	onDisk := map[string]bool{}
+	for _, migFile := range migFiles {
+		onDisk[migFile] = true
+	}
+
+	missing := false
+	for _, row := range migRows {
+		if !onDisk[row.Name] {
+			missing = true
+			break
+		}
+	}
+
+	if !missing {
+		return migRows
+	}
+
+	squashed := false
+
+	for _, migFile := range migFiles {
+		if applied[migFile] {
+			continue
+		}
+
+		// a broken file is reported when it's run
+		pair, err := GetQueriesFromFile(directory + "/" + migFile)
+		if err != nil || len(pair.Directives.Replaces) == 0 {
+			continue
+		}
+
+		replaces := map[string]bool{}
+		for _, replaced := range pair.Directives.Replaces {
+			replaces[replaced] = true
+		}
+
+		target := pair.Directives.Replaces[len(pair.Directives.Replaces)-1]
+		if !applied[target] {
+			continue
+		}
+
+		var kept, replaced []MigrationRow
+		var combined MigrationRow
+
+		for _, row := range migRows {
+			if !replaces[row.Name] {
+				kept = append(kept, row)
+				continue
+			}
+
+			replaced = append(replaced, row)
+			if row.Name == target {
+				combined = row
+			}
+		}
+
+		combined.RenamedFrom = combined.StoredName()
+		combined.Name = migFile
+		combined.Replaced = replaced
+
+		migRows = append(kept, combined)
+		applied[migFile] = true
+		squashed = true
+	}
+
+	if squashed {
+		sort.SliceStable(migRows, func(i, j int) bool {
+			return migRows[i].Name < migRows[j].Name
+		})
+	}
+
+	return migRows
+}
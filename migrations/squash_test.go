@@ -0,0 +1,108 @@
+package migrations
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSquash(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"1_a.sql", "2_b.sql", "3_c.sql"} {
+		contents := "--BEGIN MIGRATION UP--\nCREATE TABLE " + name[2:3] + " (id int);\n--END MIGRATION UP--\n" +
+			"--BEGIN MIGRATION DOWN--\nDROP TABLE " + name[2:3] + ";\n--END MIGRATION DOWN--\n"
+
+		if err := os.WriteFile(dir+"/"+name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	squashed, err := Squash(dir, "2_b.sql")
+	assert.NoError(t, err)
+	assert.Equal(t, Squashed{Filename: "2_squashed.sql", Replaces: []string{"1_a.sql", "2_b.sql"}}, squashed)
+
+	files, _ := ListFiles(dir)
+	assert.Equal(t, []string{"2_squashed.sql", "3_c.sql"}, files)
+
+	archived, _ := ListFiles(dir + "/" + ARCHIVE_DIR)
+	assert.Equal(t, []string{"1_a.sql", "2_b.sql"}, archived)
+
+	pair, err := GetQueriesFromFile(dir + "/2_squashed.sql")
+	assert.NoError(t, err)
+	assert.Equal(t, "-- 1_a.sql\nCREATE TABLE a (id int);\n-- 2_b.sql\nCREATE TABLE b (id int);\n", pair.Up)
+	assert.Equal(t, "-- 2_b.sql\nDROP TABLE b;\n-- 1_a.sql\nDROP TABLE a;\n", pair.Down, "down blocks are reversed")
+	assert.Equal(t, []string{"1_a.sql", "2_b.sql"}, pair.Directives.Replaces)
+
+	squashed, err = Squash(dir, "3_c.sql")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1_a.sql", "2_b.sql", "2_squashed.sql", "3_c.sql"}, squashed.Replaces, "an earlier squash is flattened")
+
+	rows := applySquashes(dir, []string{"3_squashed.sql"}, []MigrationRow{
+		{Id: 1, Name: "1_a.sql"},
+		{Id: 2, Name: "2_b.sql"},
+		{Id: 3, Name: "3_c.sql"},
+	})
+
+	assert.Len(t, rows, 1)
+	assert.Equal(t, "3_squashed.sql", rows[0].Name)
+	assert.Equal(t, 3, rows[0].Id, "the row of the last replaced migration is used")
+	assert.Len(t, rows[0].Replaced, 3)
+}
+
+func TestSquashFailureLeavesFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"1_a.sql", "2_b.sql"} {
+		contents := "--BEGIN MIGRATION UP--\n--END MIGRATION UP--\n--BEGIN MIGRATION DOWN--\n--END MIGRATION DOWN--\n"
+		if err := os.WriteFile(dir+"/"+name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a file in place of the archive directory makes archiving fail
+	if err := os.WriteFile(dir+"/"+ARCHIVE_DIR, []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Squash(dir, "2_b.sql")
+	assert.Error(t, err)
+
+	entries, _ := os.ReadDir(dir)
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	assert.Equal(t, []string{"1_a.sql", "2_b.sql", ARCHIVE_DIR}, names, "the squashed migration is removed and the originals are kept")
+}
+
+func TestSquashDirectives(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"1_a.sql": "--mig:tags=users\n",
+		"2_b.sql": "--mig:requires=1,0_legacy.sql\n--mig:tags=users,billing\n",
+		"3_c.sql": "--mig:timeout=5m\n",
+	}
+
+	for name, directives := range files {
+		contents := directives + "--BEGIN MIGRATION UP--\n--END MIGRATION UP--\n--BEGIN MIGRATION DOWN--\n--END MIGRATION DOWN--\n"
+		if err := os.WriteFile(dir+"/"+name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := Squash(dir, "3_c.sql")
+	assert.EqualError(t, err, "3_c.sql has a timeout directive and can't be squashed")
+
+	_, err = Squash(dir, "2_b.sql")
+	assert.NoError(t, err)
+
+	pair, err := GetQueriesFromFile(dir + "/2_squashed.sql")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0_legacy.sql"}, pair.Directives.Requires, "requirements within the range are dropped")
+	assert.Equal(t, []string{"users", "billing"}, pair.Directives.Tags)
+}
@@ -16,14 +16,15 @@ type MigrationStatus struct {
 }
 
 type MigrationRowStatus struct {
-	Migration MigrationRow `json:"migration"`
-	Status    string       `json:"status"`
+	Migration MigrationRow   `json:"migration"`
+	Status    string         `json:"status"`
+	Runs      []MigrationRun `json:"runs,omitempty"` // only populated by mig list
 }
 
 func GetStatus(cfg config.MigConfig, dbox database.DbBox) (MigrationStatus, error) {
 	var status MigrationStatus
 
-	migFiles, err := ListFiles(cfg.Migrations)
+	migFiles, err := ListFiles(cfg.Migrations, cfg.SchemaFile)
 	if err != nil {
 		return status, err
 	}
@@ -33,6 +34,14 @@ func GetStatus(cfg config.MigConfig, dbox database.DbBox) (MigrationStatus, erro
 		return status, err
 	}
 
+	renames, err := ReadRenames(cfg.Migrations)
+	if err != nil {
+		return status, err
+	}
+
+	migRows = applyRenames(migRows, renames)
+	migRows = applySquashes(cfg.Migrations, migFiles, migRows)
+
 	mfi := 0
 	mri := 0
 	didFindNext := false
@@ -51,7 +60,7 @@ func GetStatus(cfg config.MigConfig, dbox database.DbBox) (MigrationStatus, erro
 
 		if migFile == migRow.Name {
 			// This migration is present both on disk and in the database
-			status.Last = &migRow
+			status.Last = latestRow(status.Last, migRow)
 			mfi++
 			mri++
 			status.Applied++
@@ -90,7 +99,7 @@ func GetStatus(cfg config.MigConfig, dbox database.DbBox) (MigrationStatus, erro
 		// There are still rows in the database to print
 		for i := mri; i < len(migRows); i++ {
 			migRow := migRows[i]
-			status.Last = &migRow
+			status.Last = latestRow(status.Last, migRow)
 			status.Applied++
 			status.History = append(status.History, MigrationRowStatus{
 				Migration: migRow,
@@ -119,3 +128,13 @@ func GetStatus(cfg config.MigConfig, dbox database.DbBox) (MigrationStatus, erro
 
 	return status, nil
 }
+
+// The last migration is the one applied most recently, which isn't the last by name when a migration was
+// renamed or applied out of order
+func latestRow(last *MigrationRow, row MigrationRow) *MigrationRow {
+	if last == nil || row.Id > last.Id {
+		return &row
+	}
+
+	return last
+}
@@ -0,0 +1,436 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/tlhunter/mig/database"
+)
+
+// The version of the tracking tables used by this version of mig.
+// Tables created before versions were recorded are version 1.
+const SCHEMA_VERSION = 3
+
+var (
+	CREATE_MIGRATIONS = database.QueryBox{
+		Postgres: `CREATE TABLE IF NOT EXISTS migrations (
+			id serial NOT NULL,
+			name varchar(255) NULL,
+			batch int4 NULL,
+			migration_time timestamptz NULL,
+			duration_ms int8 NULL,
+			os_user varchar(255) NULL,
+			hostname varchar(255) NULL,
+			mig_version varchar(64) NULL,
+			checksum varchar(64) NULL,
+			CONSTRAINT migrations_pkey PRIMARY KEY (id)
+		);`,
+		Mysql: `CREATE TABLE IF NOT EXISTS migrations (
+			id serial NOT NULL PRIMARY KEY,
+			name varchar(255) NULL,
+			batch int4 NULL,
+			migration_time TIMESTAMP NULL,
+			duration_ms bigint NULL,
+			os_user varchar(255) NULL,
+			hostname varchar(255) NULL,
+			mig_version varchar(64) NULL,
+			checksum varchar(64) NULL
+		);`,
+		Sqlite: `CREATE TABLE IF NOT EXISTS migrations (
+			id serial NOT NULL,
+			name varchar(255) NULL,
+			batch int4 NULL,
+			migration_time timestamp NULL,
+			duration_ms integer NULL,
+			os_user varchar(255) NULL,
+			hostname varchar(255) NULL,
+			mig_version varchar(64) NULL,
+			checksum varchar(64) NULL,
+			CONSTRAINT migrations_pkey PRIMARY KEY (id)
+		);`,
+	}
+	CREATE_LOCK = database.QueryBox{
+		Postgres: `CREATE TABLE IF NOT EXISTS migrations_lock (
+			"index" serial NOT NULL,
+			is_locked int4 NULL,
+			CONSTRAINT migrations_lock_pkey PRIMARY KEY (index)
+		);`,
+		Mysql: `CREATE TABLE IF NOT EXISTS migrations_lock (
+			` + "`index`" + ` serial NOT NULL PRIMARY KEY,
+			is_locked int4 NULL
+		);`,
+		Sqlite: `CREATE TABLE IF NOT EXISTS migrations_lock (
+			"index" serial NOT NULL,
+			is_locked int4 NULL,
+			CONSTRAINT migrations_lock_pkey PRIMARY KEY ("index")
+		);`,
+	}
+	INSERT_LOCK_ROW = database.QueryBox{
+		Postgres: `INSERT INTO migrations_lock ("index", is_locked) VALUES(1, 0);`,
+		Mysql:    `INSERT INTO migrations_lock SET ` + "`index`" + ` = 1, is_locked = 0;`,
+		Sqlite:   `INSERT INTO migrations_lock ("index", is_locked) VALUES(1, 0);`,
+	}
+	COUNT_LOCK_ROWS = database.QueryBox{
+		Postgres: `SELECT COUNT(*) FROM migrations_lock;`,
+		Mysql:    `SELECT COUNT(*) FROM migrations_lock;`,
+		Sqlite:   `SELECT COUNT(*) FROM migrations_lock;`,
+	}
+	// Selecting zero rows fails when a column is missing, the same way for every database
+	RUN_COLUMNS_EXIST = database.QueryBox{
+		Postgres: `SELECT duration_ms, os_user, hostname, mig_version FROM migrations WHERE 1 = 0;`,
+		Mysql:    `SELECT duration_ms, os_user, hostname, mig_version FROM migrations WHERE 1 = 0;`,
+		Sqlite:   `SELECT duration_ms, os_user, hostname, mig_version FROM migrations WHERE 1 = 0;`,
+	}
+	CHECKSUM_EXISTS = database.QueryBox{
+		Postgres: `SELECT checksum FROM migrations WHERE 1 = 0;`,
+		Mysql:    `SELECT checksum FROM migrations WHERE 1 = 0;`,
+		Sqlite:   `SELECT checksum FROM migrations WHERE 1 = 0;`,
+	}
+	ADD_CHECKSUM_COLUMN = database.QueryBox{
+		Postgres: `ALTER TABLE migrations ADD COLUMN checksum varchar(64) NULL;`,
+		Mysql:    `ALTER TABLE migrations ADD COLUMN checksum varchar(64) NULL;`,
+		Sqlite:   `ALTER TABLE migrations ADD COLUMN checksum varchar(64) NULL;`,
+	}
+	HISTORY_EXISTS = database.QueryBox{
+		Postgres: `SELECT id FROM migrations_history WHERE 1 = 0;`,
+		Mysql:    `SELECT id FROM migrations_history WHERE 1 = 0;`,
+		Sqlite:   `SELECT id FROM migrations_history WHERE 1 = 0;`,
+	}
+	ADD_RUN_COLUMNS = database.QueryBox{
+		Postgres: `ALTER TABLE migrations
+			ADD COLUMN duration_ms int8 NULL,
+			ADD COLUMN os_user varchar(255) NULL,
+			ADD COLUMN hostname varchar(255) NULL,
+			ADD COLUMN mig_version varchar(64) NULL;`,
+		Mysql: `ALTER TABLE migrations
+			ADD COLUMN duration_ms bigint NULL,
+			ADD COLUMN os_user varchar(255) NULL,
+			ADD COLUMN hostname varchar(255) NULL,
+			ADD COLUMN mig_version varchar(64) NULL;`,
+		// sqlite only allows adding a single column per statement
+		Sqlite: `ALTER TABLE migrations ADD COLUMN duration_ms integer NULL;
+			ALTER TABLE migrations ADD COLUMN os_user varchar(255) NULL;
+			ALTER TABLE migrations ADD COLUMN hostname varchar(255) NULL;
+			ALTER TABLE migrations ADD COLUMN mig_version varchar(64) NULL;`,
+	}
+	CREATE_META = database.QueryBox{
+		Postgres: `CREATE TABLE IF NOT EXISTS mig_meta (
+			name varchar(64) NOT NULL,
+			value varchar(255) NULL,
+			CONSTRAINT mig_meta_pkey PRIMARY KEY (name)
+		);`,
+		Mysql: `CREATE TABLE IF NOT EXISTS mig_meta (
+			name varchar(64) NOT NULL PRIMARY KEY,
+			value varchar(255) NULL
+		);`,
+		Sqlite: `CREATE TABLE IF NOT EXISTS mig_meta (
+			name varchar(64) NOT NULL,
+			value varchar(255) NULL,
+			CONSTRAINT mig_meta_pkey PRIMARY KEY (name)
+		);`,
+	}
+	GET_VERSION = database.QueryBox{
+		Postgres: `SELECT value FROM mig_meta WHERE name = 'schema_version';`,
+		Mysql:    `SELECT value FROM mig_meta WHERE name = 'schema_version';`,
+		Sqlite:   `SELECT value FROM mig_meta WHERE name = 'schema_version';`,
+	}
+	CLEAR_VERSION = database.QueryBox{
+		Postgres: `DELETE FROM mig_meta WHERE name = 'schema_version';`,
+		Mysql:    `DELETE FROM mig_meta WHERE name = 'schema_version';`,
+		Sqlite:   `DELETE FROM mig_meta WHERE name = 'schema_version';`,
+	}
+	INSERT_VERSION = database.QueryBox{
+		Postgres: `INSERT INTO mig_meta (name, value) VALUES ('schema_version', $1);`,
+		Mysql:    `INSERT INTO mig_meta (name, value) VALUES ('schema_version', ?);`,
+		Sqlite:   `INSERT INTO mig_meta (name, value) VALUES ('schema_version', ?);`,
+	}
+	CREATE_HISTORY = database.QueryBox{
+		Postgres: `CREATE TABLE IF NOT EXISTS migrations_history (
+			id serial NOT NULL,
+			name varchar(255) NULL,
+			direction varchar(4) NULL,
+			batch int4 NULL,
+			migration_time timestamptz NULL,
+			duration_ms int8 NULL,
+			os_user varchar(255) NULL,
+			hostname varchar(255) NULL,
+			mig_version varchar(64) NULL,
+			CONSTRAINT migrations_history_pkey PRIMARY KEY (id)
+		);`,
+		Mysql: `CREATE TABLE IF NOT EXISTS migrations_history (
+			id serial NOT NULL PRIMARY KEY,
+			name varchar(255) NULL,
+			direction varchar(4) NULL,
+			batch int4 NULL,
+			migration_time TIMESTAMP NULL,
+			duration_ms bigint NULL,
+			os_user varchar(255) NULL,
+			hostname varchar(255) NULL,
+			mig_version varchar(64) NULL
+		);`,
+		// sqlite only generates ids for an INTEGER PRIMARY KEY
+		Sqlite: `CREATE TABLE IF NOT EXISTS migrations_history (
+			id INTEGER PRIMARY KEY,
+			name varchar(255) NULL,
+			direction varchar(4) NULL,
+			batch int4 NULL,
+			migration_time timestamp NULL,
+			duration_ms integer NULL,
+			os_user varchar(255) NULL,
+			hostname varchar(255) NULL,
+			mig_version varchar(64) NULL
+		);`,
+	}
+)
+
+// Creates the tracking tables at the current version in a single transaction.
+// mysql implicitly commits DDL so an interrupted init can leave some of the tables behind, see RepairTables.
+func CreateTables(dbox database.DbBox) error {
+	tx, err := dbox.Begin(database.TxSettings{})
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, statement := range []database.QueryBox{CREATE_MIGRATIONS, CREATE_LOCK, INSERT_LOCK_ROW, CREATE_HISTORY, CREATE_META} {
+		if _, err = tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	if err = SetTablesVersion(tx, SCHEMA_VERSION); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Creates whichever tracking tables are missing and adds the lock row when the lock table is empty.
+// Tables created by an older version of mig still need to be upgraded with UpgradeTables afterwards.
+// Returns a description of each change, which is empty when the tables were already complete.
+func RepairTables(dbox database.DbBox) ([]string, error) {
+	var changes []string
+
+	for _, table := range []struct {
+		name   string
+		create database.QueryBox
+	}{
+		{"migrations", CREATE_MIGRATIONS},
+		{"migrations_lock", CREATE_LOCK},
+	} {
+		exists, err := dbox.TableExists(table.name)
+		if err != nil {
+			return changes, err
+		}
+
+		if !exists {
+			if _, err = dbox.Exec(table.create); err != nil {
+				return changes, err
+			}
+
+			changes = append(changes, fmt.Sprintf("created the missing %s table", table.name))
+		}
+	}
+
+	var lockRows int
+	if err := dbox.QueryRow(COUNT_LOCK_ROWS).Scan(&lockRows); err != nil {
+		return changes, err
+	}
+
+	if lockRows == 0 {
+		if _, err := dbox.Exec(INSERT_LOCK_ROW); err != nil {
+			return changes, err
+		}
+
+		changes = append(changes, "added the missing migrations_lock row")
+	}
+
+	version, err := GetTablesVersion(dbox)
+	if err != nil {
+		return changes, err
+	}
+
+	// older tables are completed by UpgradeTables, which requires the lock
+	if version >= SCHEMA_VERSION && !historyExists(dbox) {
+		if _, err := dbox.Exec(CREATE_HISTORY); err != nil {
+			return changes, err
+		}
+
+		changes = append(changes, "created the missing migrations_history table")
+	}
+
+	return changes, nil
+}
+
+type tablesUpgrade struct {
+	Version     int
+	Description string
+	Statements  func(database.DbBox) []database.QueryBox // the statements needed to upgrade the current tables
+}
+
+// Every change to the tracking tables, oldest first. Each upgrade brings the tables to its version
+// and should tolerate tables which are already partially upgraded.
+var TABLES_UPGRADES = []tablesUpgrade{
+	{
+		Version:     2,
+		Description: "record how each migration was run and keep a history of runs",
+		Statements: func(dbox database.DbBox) []database.QueryBox {
+			if RunColumnsExist(dbox) {
+				return []database.QueryBox{CREATE_HISTORY}
+			}
+
+			return []database.QueryBox{ADD_RUN_COLUMNS, CREATE_HISTORY}
+		},
+	},
+	{
+		Version:     3,
+		Description: "record a checksum of each migration file to recognize renamed files",
+		Statements: func(dbox database.DbBox) []database.QueryBox {
+			if ChecksumExists(dbox) {
+				return nil
+			}
+
+			return []database.QueryBox{ADD_CHECKSUM_COLUMN}
+		},
+	},
+}
+
+// Whether the migrations table has the columns describing how each migration was run
+func RunColumnsExist(dbox database.DbBox) bool {
+	rows, err := dbox.Query(RUN_COLUMNS_EXIST)
+	if err != nil {
+		return false
+	}
+
+	rows.Close()
+
+	return true
+}
+
+// Whether the migrations table has the checksum column
+func ChecksumExists(dbox database.DbBox) bool {
+	rows, err := dbox.Query(CHECKSUM_EXISTS)
+	if err != nil {
+		return false
+	}
+
+	rows.Close()
+
+	return true
+}
+
+func historyExists(dbox database.DbBox) bool {
+	rows, err := dbox.Query(HISTORY_EXISTS)
+	if err != nil {
+		return false
+	}
+
+	rows.Close()
+
+	return true
+}
+
+// Returns the version of the tracking tables, which is 1 when the mig_meta table is missing
+func GetTablesVersion(dbox database.DbBox) (int, error) {
+	exists, err := dbox.TableExists("mig_meta")
+	if err != nil {
+		return 0, err
+	}
+
+	if !exists {
+		return 1, nil
+	}
+
+	var value string
+
+	// the row is only missing when an upgrade was interrupted before it was committed
+	err = dbox.QueryRow(GET_VERSION).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("mig_meta contains an invalid schema_version '%s'", value)
+	}
+
+	return version, nil
+}
+
+// Stores the version of the tracking tables, the box should be bound to a transaction
+func SetTablesVersion(dbox database.DbBox, version int) error {
+	if _, err := dbox.Exec(CLEAR_VERSION); err != nil {
+		return err
+	}
+
+	_, err := dbox.Exec(INSERT_VERSION, strconv.Itoa(version))
+	return err
+}
+
+// Applies every upgrade newer than the version of the tracking tables, each in its own transaction.
+// Returns the descriptions of the applied upgrades. It should only be called while holding the lock.
+func UpgradeTables(dbox database.DbBox) ([]string, error) {
+	var applied []string
+
+	version, err := GetTablesVersion(dbox)
+	if err != nil {
+		return applied, err
+	}
+
+	if version > SCHEMA_VERSION {
+		return applied, fmt.Errorf("the tables are at version %d which is newer than version %d used by this version of mig", version, SCHEMA_VERSION)
+	}
+
+	if version == SCHEMA_VERSION {
+		return applied, nil
+	}
+
+	if _, err = dbox.Exec(CREATE_META); err != nil {
+		return applied, err
+	}
+
+	for _, upgrade := range TABLES_UPGRADES {
+		if upgrade.Version <= version {
+			continue
+		}
+
+		if err = applyUpgrade(dbox, upgrade); err != nil {
+			return applied, fmt.Errorf("unable to upgrade the tables to version %d: %w", upgrade.Version, err)
+		}
+
+		applied = append(applied, upgrade.Description)
+	}
+
+	return applied, nil
+}
+
+// mysql implicitly commits DDL so the transaction only keeps the version consistent for postgres and sqlite
+func applyUpgrade(dbox database.DbBox, upgrade tablesUpgrade) error {
+	// probing for a missing table or column aborts a postgres transaction so it's done before beginning one
+	statements := upgrade.Statements(dbox)
+
+	tx, err := dbox.Begin(database.TxSettings{})
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	if err = SetTablesVersion(tx, upgrade.Version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
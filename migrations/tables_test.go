@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tlhunter/mig/database"
+)
+
+func TestGetTablesVersion(t *testing.T) {
+	dbox, err := database.Connect("sqlite::memory:", database.ConnectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := GetTablesVersion(dbox)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version, "tables without mig_meta are version 1")
+
+	_, err = dbox.Db.Exec(`CREATE TABLE mig_meta (name text, value text);
+	INSERT INTO mig_meta (name, value) VALUES ('schema_version', '3');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err = GetTablesVersion(dbox)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, version)
+
+	dbox.Db.Close()
+
+	_, err = GetTablesVersion(dbox)
+	assert.Error(t, err, "errors other than a missing table aren't mistaken for version 1")
+}
@@ -93,6 +93,15 @@ func (r *Response) SetErrorDetails(details error) {
 	r.Details = details.Error()
 }
 
+// Copies the error from another response, such as one returned by a helper
+func (r *Response) SetErrorFrom(other *Response) {
+	r.ExitStatus = other.ExitStatus
+	r.Error = other.Error
+	r.ErrorCode = other.ErrorCode
+	r.Details = other.Details
+	r.ErrorMultiline = other.ErrorMultiline
+}
+
 func (r Response) Display(encode bool) error {
 	if encode {
 		if r.Serializable != nil {
@@ -5,6 +5,9 @@
 // currently you need to destroy the migration tables before every test run
 
 import assert from 'node:assert';
+import os from 'node:os';
+
+let migVersion;
 
 {
     console.log('### VERSION');
@@ -12,6 +15,7 @@ import assert from 'node:assert';
     const stdout = JSON.parse(await $`../../mig version --json`);
 
     assert.equal(typeof stdout.version, 'string', '.version is a string');
+    migVersion = stdout.version;
     assert.equal(typeof stdout.build_time, 'string', '.build_time is a string');
 }
 
@@ -185,13 +189,23 @@ import assert from 'node:assert';
     assert.equal(stdout.length, 2, 'output contains two entries');
 
     const addUsersTable = stdout[0];
-    assert.equal(Object.keys(addUsersTable).length, 2, '[0] has two keys');
-    assert.equal(Object.keys(addUsersTable.migration).length, 4, '[0].migration has four keys');
+    assert.equal(Object.keys(addUsersTable).length, 3, '[0] has three keys');
+    assert.equal(Object.keys(addUsersTable.migration).length, 9, '[0].migration has nine keys');
     assert.equal(addUsersTable.migration.id, 1, '[0].migration.id is correct');
     assert.equal(addUsersTable.migration.name, '20230101120058_add_users_table.sql', '[0].migration.name is correct');
     assert.equal(addUsersTable.migration.batch, 1, '[0].migration.batch is correct');
     assert.equal(typeof addUsersTable.migration.time, 'string', '[0].migration.time is present');
+    assert.ok(Number.isInteger(addUsersTable.migration.duration_ms) && addUsersTable.migration.duration_ms >= 0, '[0].migration.duration_ms is present');
+    assert.equal(addUsersTable.migration.os_user, os.userInfo().username, '[0].migration.os_user is correct');
+    assert.equal(addUsersTable.migration.hostname, os.hostname(), '[0].migration.hostname is correct');
+    assert.equal(addUsersTable.migration.mig_version, migVersion, '[0].migration.mig_version is correct');
+    assert.match(addUsersTable.migration.checksum, /^[0-9a-f]{64}$/, '[0].migration.checksum is a sha256');
     assert.equal(addUsersTable.status, 'applied', '[0].status is correct');
+    assert.equal(addUsersTable.runs.length, 1, '[0] has run once');
+    assert.equal(addUsersTable.runs[0].direction, 'up', '[0].runs[0].direction is correct');
+    assert.equal(addUsersTable.runs[0].batch, 1, '[0].runs[0].batch is correct');
+    assert.equal(addUsersTable.runs[0].os_user, os.userInfo().username, '[0].runs[0].os_user is correct');
+    assert.equal(addUsersTable.runs[0].hostname, os.hostname(), '[0].runs[0].hostname is correct');
 
     const addEmailToUsers = stdout[1];
     assert.equal(Object.keys(addEmailToUsers).length, 2, '[1] has two keys');
@@ -221,13 +235,16 @@ import assert from 'node:assert';
     assert.equal(stdout.length, 2, 'output contains two entries');
 
     const addEmailToUsers = stdout[1];
-    assert.equal(Object.keys(addEmailToUsers).length, 2, '[1] has two keys');
-    assert.equal(Object.keys(addEmailToUsers.migration).length, 4, '[1].migration has four keys');
+    assert.equal(Object.keys(addEmailToUsers).length, 3, '[1] has three keys');
+    assert.equal(Object.keys(addEmailToUsers.migration).length, 9, '[1].migration has nine keys');
     assert.equal(addEmailToUsers.migration.id, 2, '[1].migration.id is correct');
     assert.equal(addEmailToUsers.migration.name, '20230101120107_add_email_to_users.sql', '[0].migration.name is correct');
     assert.equal(addEmailToUsers.migration.batch, 2, '[1].migration.batch is correct');
     assert.equal(typeof addEmailToUsers.migration.time, 'string', '[1].migration.time is present');
+    assert.equal(addEmailToUsers.migration.mig_version, migVersion, '[1].migration.mig_version is correct');
     assert.equal(addEmailToUsers.status, 'applied', '[1].status is correct');
+    assert.equal(addEmailToUsers.runs.length, 1, '[1] has run once');
+    assert.equal(addEmailToUsers.runs[0].batch, 2, '[1].runs[0].batch is correct');
 }
     
 {
@@ -266,9 +283,10 @@ import assert from 'node:assert';
     assert.equal(addUsersTable.status, 'applied', '[0].status is correct');
 
     const addEmailToUsers = stdout[1];
-    assert.equal(Object.keys(addEmailToUsers).length, 2, '[1] has two keys');
+    assert.equal(Object.keys(addEmailToUsers).length, 3, '[1] has three keys');
     assert.equal(addEmailToUsers.migration.name, '20230101120107_add_email_to_users.sql', '[1].migration.name is correct');
     assert.equal(addEmailToUsers.status, 'unapplied', '[1].status is correct');
+    assert.deepEqual(addEmailToUsers.runs.map(run => run.direction), ['up', 'down'], '[1] was run up then down');
 }
 
 {
@@ -287,8 +305,9 @@ import assert from 'node:assert';
     assert.equal(stdout.length, 2, 'output contains two entries');
 
     const addUsersTable = stdout[0];
-    assert.equal(Object.keys(addUsersTable).length, 2, '[0] has two keys');
+    assert.equal(Object.keys(addUsersTable).length, 3, '[0] has three keys');
     assert.equal(addUsersTable.status, 'unapplied', '[0].status is correct');
+    assert.deepEqual(addUsersTable.runs.map(run => run.direction), ['up', 'down'], '[0] was run up then down');
 
     const addEmailToUsers = stdout[1];
     assert.equal(addEmailToUsers.status, 'unapplied', '[1].status is correct');